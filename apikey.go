@@ -0,0 +1,223 @@
+package thttp
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// APIKey is an API key known by a store
+type APIKey struct {
+	// Hash is the hexadecimal SHA-256 of the key (see HashAPIKey)
+	Hash string `json:"hash"`
+	// Owner identifies the client the key was issued to
+	Owner string `json:"owner"`
+	// Scopes granted to the key
+	Scopes []string `json:"scopes,omitempty"`
+	// ExpiresAt is the expiry date of the key, the key never expires if it is
+	// not set
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// expired tells if the key is expired at the specified time
+func (k APIKey) expired(now time.Time) bool {
+	return k.ExpiresAt.IsZero() == false && now.After(k.ExpiresAt) == true
+}
+
+// APIKeyStore find the API keys sent by the clients
+type APIKeyStore interface {
+	// Lookup return the API key matching the key sent by the client. false is
+	// returned if the key is unknown.
+	Lookup(key string) (APIKey, bool)
+}
+
+// APIKeyConfig define the configuration of the APIKeyAuth middleware
+type APIKeyConfig struct {
+	// Header containing the key. Default to "X-API-Key".
+	Header string
+	// Query is the name of the query parameter containing the key, the query
+	// is not read if it is empty
+	Query string
+	// Cookie is the name of the cookie containing the key, the cookies are not
+	// read if it is empty
+	Cookie string
+	// Realm is sent in the WWW-Authenticate header. Default to "Restricted".
+	Realm string
+	// Store holds the valid keys
+	Store APIKeyStore
+}
+
+// APIKeyAuth return a middleware authenticating the requests with an API key
+// read from the header, then the query parameter and then the cookie of the
+// config. The principal, whose ID is the owner of the key, is injected in
+// `req.M["principal"]`. Requests without a valid key are answered with a 401
+// status code. It panics if cfg.Store is nil.
+func APIKeyAuth(cfg APIKeyConfig) nanux.Middleware {
+	if cfg.Store == nil {
+		panic("APIKeyAuth : cfg.Store must be set")
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = "X-API-Key"
+	}
+
+	if cfg.Realm == "" {
+		cfg.Realm = "Restricted"
+	}
+
+	challenge := `APIKey realm="` + cfg.Realm + `"`
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			key := apiKeyFromRequest(httpCtx, cfg)
+
+			if key == "" {
+				unauthorized(httpCtx, challenge)
+
+				return nil, nil
+			}
+
+			apiKey, ok := cfg.Store.Lookup(key)
+
+			if ok == false || apiKey.expired(time.Now()) == true {
+				GetLogger(req).Debug().Bool("known", ok).Msg("APIKeyAuth : invalid key")
+				unauthorized(httpCtx, challenge+`, error="invalid_key"`)
+
+				return nil, nil
+			}
+
+			req.M["principal"] = Principal{ID: apiKey.Owner, Scopes: apiKey.Scopes}
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// apiKeyFromRequest return the API key sent in the header, the query parameter
+// or the cookie of the config
+func apiKeyFromRequest(httpCtx *fasthttp.RequestCtx, cfg APIKeyConfig) string {
+	if key := httpCtx.Request.Header.Peek(cfg.Header); len(key) > 0 {
+		return string(key)
+	}
+
+	if cfg.Query != "" {
+		if key := httpCtx.QueryArgs().Peek(cfg.Query); len(key) > 0 {
+			return string(key)
+		}
+	}
+
+	if cfg.Cookie != "" {
+		return string(httpCtx.Request.Header.Cookie(cfg.Cookie))
+	}
+
+	return ""
+}
+
+// HashAPIKey return the hexadecimal SHA-256 of the key as stored by
+// FileAPIKeyStore
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// storedAPIKey is an API key with its decoded hash
+type storedAPIKey struct {
+	APIKey
+	hash []byte
+}
+
+// FileAPIKeyStore holds the API keys of a JSON file. The file contains an
+// array of keys, eg:
+//
+//	[{"hash": "<sha256 hex>", "owner": "partner", "scopes": ["orders:read"], "expires_at": "2030-01-01T00:00:00Z"}]
+//
+// Only the hashes of the keys are stored so the file does not leak them.
+type FileAPIKeyStore struct {
+	path string
+
+	mu   sync.RWMutex
+	keys []storedAPIKey
+}
+
+// NewFileAPIKeyStore load the API keys of the file
+func NewFileAPIKeyStore(path string) (*FileAPIKeyStore, error) {
+	s := &FileAPIKeyStore{path: path}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reload read the file again, eg: after a key has been added or revoked. The
+// keys previously loaded are kept if the file is invalid.
+func (s *FileAPIKeyStore) Reload() error {
+	data, err := ioutil.ReadFile(s.path)
+
+	if err != nil {
+		return err
+	}
+
+	var apiKeys []APIKey
+
+	if err := json.Unmarshal(data, &apiKeys); err != nil {
+		return err
+	}
+
+	keys := make([]storedAPIKey, len(apiKeys))
+
+	for i, apiKey := range apiKeys {
+		hash, err := hex.DecodeString(apiKey.Hash)
+
+		if err != nil || len(hash) != sha256.Size {
+			return errors.New("FileAPIKeyStore : invalid hash for owner " + apiKey.Owner)
+		}
+
+		keys[i] = storedAPIKey{APIKey: apiKey, hash: hash}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Lookup return the API key matching the key. The key is compared to all the
+// stored hashes in constant time so that the duration of the lookup does not
+// tell how close the key is to a valid one.
+func (s *FileAPIKeyStore) Lookup(key string) (APIKey, bool) {
+	sum := sha256.Sum256([]byte(key))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found APIKey
+	ok := false
+
+	// all the hashes are compared even after a match
+	for _, stored := range s.keys {
+		if subtle.ConstantTimeCompare(sum[:], stored.hash) == 1 {
+			found = stored.APIKey
+			ok = true
+		}
+	}
+
+	return found, ok
+}
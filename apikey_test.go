@@ -0,0 +1,243 @@
+package thttp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// staticAPIKeyStore is a store whose keys are not hashed
+type staticAPIKeyStore map[string]APIKey
+
+func (s staticAPIKeyStore) Lookup(key string) (APIKey, bool) {
+	apiKey, ok := s[key]
+
+	return apiKey, ok
+}
+
+func TestHashAPIKey(t *testing.T) {
+	want := "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+
+	if got := HashAPIKey("secret"); got != want {
+		t.Errorf("HashAPIKey() = %v, want %v", got, want)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	store := staticAPIKeyStore{
+		"partner-key": {Owner: "partner", Scopes: []string{"orders:read"}},
+		"expired-key": {Owner: "old-partner", ExpiresAt: time.Now().Add(-time.Hour)},
+		"future-key":  {Owner: "new-partner", ExpiresAt: time.Now().Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name           string
+		cfg            APIKeyConfig
+		header         string
+		query          string
+		cookie         string
+		wantStatusCode int
+		wantPrincipal  Principal
+		wantChallenge  string
+	}{
+		{
+			name:           "key in the default header",
+			cfg:            APIKeyConfig{},
+			header:         "partner-key",
+			wantStatusCode: 200,
+			wantPrincipal:  Principal{ID: "partner", Scopes: []string{"orders:read"}},
+		},
+		{
+			name:           "key in the query",
+			cfg:            APIKeyConfig{Query: "api_key"},
+			query:          "partner-key",
+			wantStatusCode: 200,
+			wantPrincipal:  Principal{ID: "partner", Scopes: []string{"orders:read"}},
+		},
+		{
+			name:           "key in the cookie",
+			cfg:            APIKeyConfig{Cookie: "api_key"},
+			cookie:         "partner-key",
+			wantStatusCode: 200,
+			wantPrincipal:  Principal{ID: "partner", Scopes: []string{"orders:read"}},
+		},
+		{
+			name:           "query not read if not configured",
+			cfg:            APIKeyConfig{},
+			query:          "partner-key",
+			wantStatusCode: 401,
+			wantChallenge:  `APIKey realm="Restricted"`,
+		},
+		{
+			name:           "key not expired yet",
+			cfg:            APIKeyConfig{},
+			header:         "future-key",
+			wantStatusCode: 200,
+			wantPrincipal:  Principal{ID: "new-partner"},
+		},
+		{
+			name:           "expired key",
+			cfg:            APIKeyConfig{Realm: "partners"},
+			header:         "expired-key",
+			wantStatusCode: 401,
+			wantChallenge:  `APIKey realm="partners", error="invalid_key"`,
+		},
+		{
+			name:           "unknown key",
+			cfg:            APIKeyConfig{},
+			header:         "other-key",
+			wantStatusCode: 401,
+			wantChallenge:  `APIKey realm="Restricted", error="invalid_key"`,
+		},
+		{
+			name:           "missing key",
+			cfg:            APIKeyConfig{},
+			wantStatusCode: 401,
+			wantChallenge:  `APIKey realm="Restricted"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Store = store
+
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.SetRequestURI("/orders?api_key=" + tt.query)
+
+			if tt.header != "" {
+				httpCtx.Request.Header.Set("X-API-Key", tt.header)
+			}
+
+			if tt.cookie != "" {
+				httpCtx.Request.Header.SetCookie("api_key", tt.cookie)
+			}
+
+			req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+
+			var gotPrincipal Principal
+
+			_, err := APIKeyAuth(tt.cfg)(func(_ *interface{}, req nanux.Request) ([]byte, error) {
+				gotPrincipal, _ = GetPrincipal(req)
+
+				return nil, nil
+			})(nil, req)
+
+			if err != nil {
+				t.Fatalf("APIKeyAuth() - error occured when calling handler - %s", err)
+			}
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("APIKeyAuth() - status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			if reflect.DeepEqual(gotPrincipal, tt.wantPrincipal) == false {
+				t.Errorf("APIKeyAuth() - principal = %v, want %v", gotPrincipal, tt.wantPrincipal)
+			}
+
+			if challenge := string(httpCtx.Response.Header.Peek("WWW-Authenticate")); challenge != tt.wantChallenge {
+				t.Errorf("APIKeyAuth() - WWW-Authenticate = %s, want %s", challenge, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth_missingStore(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("APIKeyAuth() must panic when the store is not set")
+		}
+	}()
+
+	APIKeyAuth(APIKeyConfig{})
+}
+
+func TestFileAPIKeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thttp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	t.Run("load errors", func(t *testing.T) {
+		paths := []string{
+			filepath.Join(dir, "missing"),
+			writeFile("invalid-json", "{"),
+			writeFile("invalid-hash", `[{"hash": "not-hex", "owner": "partner"}]`),
+			writeFile("short-hash", `[{"hash": "abcd", "owner": "partner"}]`),
+		}
+
+		for _, path := range paths {
+			if _, err := NewFileAPIKeyStore(path); err == nil {
+				t.Errorf("NewFileAPIKeyStore(%s) must fail", path)
+			}
+		}
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		path := writeFile("keys.json", `[
+			{"hash": "`+HashAPIKey("partner-key")+`", "owner": "partner", "scopes": ["orders:read"], "expires_at": "2030-01-01T00:00:00Z"},
+			{"hash": "`+HashAPIKey("other-key")+`", "owner": "other"}
+		]`)
+
+		store, err := NewFileAPIKeyStore(path)
+
+		if err != nil {
+			t.Fatalf("NewFileAPIKeyStore() err = %v", err)
+		}
+
+		apiKey, ok := store.Lookup("partner-key")
+		wantExpiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		if ok == false || apiKey.Owner != "partner" || reflect.DeepEqual(apiKey.Scopes, []string{"orders:read"}) == false || apiKey.ExpiresAt.Equal(wantExpiresAt) == false {
+			t.Errorf("FileAPIKeyStore.Lookup() = %+v, %v", apiKey, ok)
+		}
+
+		if apiKey, ok := store.Lookup("other-key"); ok == false || apiKey.Owner != "other" || apiKey.ExpiresAt.IsZero() == false {
+			t.Errorf("FileAPIKeyStore.Lookup() = %+v, %v", apiKey, ok)
+		}
+
+		if _, ok := store.Lookup("unknown-key"); ok == true {
+			t.Errorf("FileAPIKeyStore.Lookup() must not find an unknown key")
+		}
+
+		// revoke a key and reload the file
+		writeFile("keys.json", `[{"hash": "`+HashAPIKey("other-key")+`", "owner": "other"}]`)
+
+		if err := store.Reload(); err != nil {
+			t.Fatalf("FileAPIKeyStore.Reload() err = %v", err)
+		}
+
+		if _, ok := store.Lookup("partner-key"); ok == true {
+			t.Errorf("FileAPIKeyStore.Lookup() must not find a revoked key")
+		}
+
+		// keys are kept when the file becomes invalid
+		writeFile("keys.json", "{")
+
+		if err := store.Reload(); err == nil {
+			t.Errorf("FileAPIKeyStore.Reload() must fail with an invalid file")
+		}
+
+		if _, ok := store.Lookup("other-key"); ok == false {
+			t.Errorf("FileAPIKeyStore.Lookup() must keep the keys when the reload fails")
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package thttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Principal is the client authenticated by an authentication middleware
+type Principal struct {
+	// ID identifies the client (eg: the user name or the subject of a token)
+	ID string
+	// Scopes granted to the client
+	Scopes []string
+	// Roles of the client
+	Roles []string
+	// Claims are extra information about the client (eg: the claims of a JWT)
+	Claims map[string]interface{}
+}
+
+// GetPrincipal return the principal injected in the nanux request by an
+// authentication middleware. false is returned if the request has not been
+// authenticated.
+func GetPrincipal(req nanux.Request) (Principal, bool) {
+	principal, ok := req.M["principal"].(Principal)
+
+	return principal, ok
+}
+
+// BasicAuthConfig define the configuration of the BasicAuth middleware
+type BasicAuthConfig struct {
+	// Realm is sent in the WWW-Authenticate header. Default to "Restricted".
+	Realm string
+	// Validate tells if the credentials are valid (eg: Htpasswd.Validate)
+	Validate func(username, password string) bool
+}
+
+// BasicAuth return a middleware authenticating the requests with the HTTP
+// Basic authentication scheme. The principal, whose ID is the username, is
+// injected in `req.M["principal"]`. Requests without valid credentials are
+// answered with a 401 status code. It panics if cfg.Validate is nil.
+func BasicAuth(cfg BasicAuthConfig) nanux.Middleware {
+	if cfg.Validate == nil {
+		panic("BasicAuth : cfg.Validate must be set")
+	}
+
+	if cfg.Realm == "" {
+		cfg.Realm = "Restricted"
+	}
+
+	challenge := `Basic realm="` + cfg.Realm + `", charset="UTF-8"`
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			username, password, ok := parseBasicAuth(httpCtx)
+
+			if ok == false || cfg.Validate(username, password) == false {
+				unauthorized(httpCtx, challenge)
+
+				return nil, nil
+			}
+
+			req.M["principal"] = Principal{ID: username}
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// BearerAuthConfig define the configuration of the BearerAuth middleware
+type BearerAuthConfig struct {
+	// Realm is sent in the WWW-Authenticate header. Default to "Restricted".
+	Realm string
+	// Validate return the principal associated to the token or an error if the
+	// token is not valid
+	Validate func(token string) (Principal, error)
+}
+
+// BearerAuth return a middleware authenticating the requests with a bearer
+// token sent in the Authorization header. The principal returned by the
+// validator is injected in `req.M["principal"]`. Requests without a valid
+// token are answered with a 401 status code. It panics if cfg.Validate is nil.
+func BearerAuth(cfg BearerAuthConfig) nanux.Middleware {
+	if cfg.Validate == nil {
+		panic("BearerAuth : cfg.Validate must be set")
+	}
+
+	if cfg.Realm == "" {
+		cfg.Realm = "Restricted"
+	}
+
+	challenge := `Bearer realm="` + cfg.Realm + `"`
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			token, ok := parseBearerAuth(httpCtx)
+
+			if ok == false {
+				unauthorized(httpCtx, challenge)
+
+				return nil, nil
+			}
+
+			principal, err := cfg.Validate(token)
+
+			if err != nil {
+				GetLogger(req).Debug().Err(err).Msg("BearerAuth : invalid token")
+				unauthorized(httpCtx, challenge+`, error="invalid_token"`)
+
+				return nil, nil
+			}
+
+			req.M["principal"] = principal
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// unauthorized respond with a 401 status code and the challenge of the
+// authentication scheme
+func unauthorized(httpCtx *fasthttp.RequestCtx, challenge string) {
+	httpCtx.Response.Header.Set("WWW-Authenticate", challenge)
+	httpCtx.SetStatusCode(fasthttp.StatusUnauthorized)
+}
+
+// parseAuthorization return the credentials of the Authorization header if it
+// uses the specified scheme
+func parseAuthorization(httpCtx *fasthttp.RequestCtx, scheme string) (string, bool) {
+	auth := string(httpCtx.Request.Header.Peek("Authorization"))
+
+	if len(auth) <= len(scheme) || strings.EqualFold(auth[:len(scheme)], scheme) == false || auth[len(scheme)] != ' ' {
+		return "", false
+	}
+
+	return strings.TrimSpace(auth[len(scheme)+1:]), true
+}
+
+// parseBasicAuth return the username and the password of the Basic
+// Authorization header
+func parseBasicAuth(httpCtx *fasthttp.RequestCtx) (username, password string, ok bool) {
+	encoded, ok := parseAuthorization(httpCtx, "Basic")
+
+	if ok == false {
+		return
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return "", "", false
+	}
+
+	i := bytes.IndexByte(decoded, ':')
+
+	if i < 0 {
+		return "", "", false
+	}
+
+	return string(decoded[:i]), string(decoded[i+1:]), true
+}
+
+// parseBearerAuth return the token of the Bearer Authorization header
+func parseBearerAuth(httpCtx *fasthttp.RequestCtx) (string, bool) {
+	token, ok := parseAuthorization(httpCtx, "Bearer")
+
+	return token, ok && token != ""
+}
+
+// dummyHash is compared to the passwords of unknown users so that checking
+// them takes as long as checking the known ones
+var dummyHash = []byte("$2a$10$BRtrndHPjWtU5LzuuA1ExutJm54xKO.YWKuvp2NddwlzpuVRTbOXu")
+
+// Htpasswd holds the users of a htpasswd file. Only bcrypt hashes are
+// supported (`htpasswd -B`).
+type Htpasswd struct {
+	users map[string][]byte
+}
+
+// NewHtpasswd load the users of the htpasswd file
+func NewHtpasswd(path string) (*Htpasswd, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	h := &Htpasswd{users: make(map[string][]byte)}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") == true {
+			continue
+		}
+
+		i := strings.IndexByte(line, ':')
+
+		if i < 0 {
+			return nil, errors.New("Htpasswd : malformed line, missing separator")
+		}
+
+		if strings.HasPrefix(line[i+1:], "$2") == false {
+			return nil, errors.New("Htpasswd : only bcrypt hashes are supported, invalid hash for user " + line[:i])
+		}
+
+		h.users[line[:i]] = []byte(line[i+1:])
+	}
+
+	return h, scanner.Err()
+}
+
+// Validate tells if the password of the user is valid
+func (h *Htpasswd) Validate(username, password string) bool {
+	hash, ok := h.users[username]
+
+	if ok == false {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
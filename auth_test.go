@@ -0,0 +1,251 @@
+package thttp
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// secretHash is the bcrypt hash of "secret"
+const secretHash = "$2a$04$LIEHA0RIoKUo496EcMWMLuiUzHVCulnWwE/7ctI1ltw7x4uOJn46m"
+
+func TestGetPrincipal(t *testing.T) {
+	principal := Principal{ID: "alice"}
+
+	tests := []struct {
+		name          string
+		req           nanux.Request
+		wantPrincipal Principal
+		wantOK        bool
+	}{
+		{
+			name: "principal not provided",
+			req:  nanux.Request{M: make(map[string]interface{})},
+		},
+		{
+			name: "principal is not of type Principal",
+			req:  nanux.Request{M: map[string]interface{}{"principal": "wrong type"}},
+		},
+		{
+			name:          "principal type is Principal",
+			req:           nanux.Request{M: map[string]interface{}{"principal": principal}},
+			wantPrincipal: principal,
+			wantOK:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPrincipal, gotOK := GetPrincipal(tt.req)
+
+			if !reflect.DeepEqual(gotPrincipal, tt.wantPrincipal) || gotOK != tt.wantOK {
+				t.Errorf("GetPrincipal() = %v, %v, want %v, %v", gotPrincipal, gotOK, tt.wantPrincipal, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	cfg := BasicAuthConfig{
+		Realm: "admin",
+		Validate: func(username, password string) bool {
+			return username == "alice" && password == "secret"
+		},
+	}
+
+	tests := []struct {
+		name           string
+		authorization  string
+		wantStatusCode int
+		wantPrincipal  string
+	}{
+		{name: "valid credentials", authorization: basic("alice:secret"), wantStatusCode: 200, wantPrincipal: "alice"},
+		{name: "scheme is case insensitive", authorization: "basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret")), wantStatusCode: 200, wantPrincipal: "alice"},
+		{name: "invalid password", authorization: basic("alice:wrong"), wantStatusCode: 401},
+		{name: "missing separator", authorization: basic("alice"), wantStatusCode: 401},
+		{name: "invalid base64", authorization: "Basic !!!", wantStatusCode: 401},
+		{name: "other scheme", authorization: "Bearer token", wantStatusCode: 401},
+		{name: "missing header", wantStatusCode: 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("Authorization", tt.authorization)
+			req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+
+			var gotPrincipal Principal
+
+			_, err := BasicAuth(cfg)(func(_ *interface{}, req nanux.Request) ([]byte, error) {
+				gotPrincipal, _ = GetPrincipal(req)
+
+				return nil, nil
+			})(nil, req)
+
+			if err != nil {
+				t.Fatalf("BasicAuth() - error occured when calling handler - %s", err)
+			}
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("BasicAuth() - status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			if gotPrincipal.ID != tt.wantPrincipal {
+				t.Errorf("BasicAuth() - principal = %v, want %v", gotPrincipal.ID, tt.wantPrincipal)
+			}
+
+			if wantChallenge := `Basic realm="admin", charset="UTF-8"`; tt.wantStatusCode == 401 && string(httpCtx.Response.Header.Peek("WWW-Authenticate")) != wantChallenge {
+				t.Errorf("BasicAuth() - WWW-Authenticate = %s, want %s", httpCtx.Response.Header.Peek("WWW-Authenticate"), wantChallenge)
+			}
+		})
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	cfg := BearerAuthConfig{
+		Validate: func(token string) (Principal, error) {
+			if token != "valid-token" {
+				return Principal{}, errors.New("unknown token")
+			}
+
+			return Principal{ID: "service", Scopes: []string{"orders:read"}}, nil
+		},
+	}
+
+	tests := []struct {
+		name           string
+		authorization  string
+		wantStatusCode int
+		wantPrincipal  string
+		wantChallenge  string
+	}{
+		{name: "valid token", authorization: "Bearer valid-token", wantStatusCode: 200, wantPrincipal: "service"},
+		{name: "invalid token", authorization: "Bearer other-token", wantStatusCode: 401, wantChallenge: `Bearer realm="Restricted", error="invalid_token"`},
+		{name: "empty token", authorization: "Bearer ", wantStatusCode: 401, wantChallenge: `Bearer realm="Restricted"`},
+		{name: "missing header", wantStatusCode: 401, wantChallenge: `Bearer realm="Restricted"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("Authorization", tt.authorization)
+			req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+
+			var gotPrincipal Principal
+
+			_, err := BearerAuth(cfg)(func(_ *interface{}, req nanux.Request) ([]byte, error) {
+				gotPrincipal, _ = GetPrincipal(req)
+
+				return nil, nil
+			})(nil, req)
+
+			if err != nil {
+				t.Fatalf("BearerAuth() - error occured when calling handler - %s", err)
+			}
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("BearerAuth() - status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			if gotPrincipal.ID != tt.wantPrincipal {
+				t.Errorf("BearerAuth() - principal = %v, want %v", gotPrincipal.ID, tt.wantPrincipal)
+			}
+
+			if challenge := string(httpCtx.Response.Header.Peek("WWW-Authenticate")); challenge != tt.wantChallenge {
+				t.Errorf("BearerAuth() - WWW-Authenticate = %s, want %s", challenge, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestAuth_missingValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		middleware func()
+	}{
+		{name: "basic", middleware: func() { BasicAuth(BasicAuthConfig{}) }},
+		{name: "bearer", middleware: func() { BearerAuth(BearerAuthConfig{}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("the middleware must panic when cfg.Validate is not set")
+				}
+			}()
+
+			tt.middleware()
+		})
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thttp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	t.Run("load errors", func(t *testing.T) {
+		paths := []string{
+			filepath.Join(dir, "missing"),
+			writeFile("md5", "alice:$apr1$salt$hash\n"),
+			writeFile("malformed", "alice\n"),
+		}
+
+		for _, path := range paths {
+			if _, err := NewHtpasswd(path); err == nil {
+				t.Errorf("NewHtpasswd(%s) must fail", path)
+			}
+		}
+	})
+
+	h, err := NewHtpasswd(writeFile("htpasswd", "# users\n\nalice:"+secretHash+"\n"))
+
+	if err != nil {
+		t.Fatalf("NewHtpasswd() err = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     bool
+	}{
+		{name: "valid password", username: "alice", password: "secret", want: true},
+		{name: "invalid password", username: "alice", password: "wrong", want: false},
+		{name: "unknown user", username: "bob", password: "secret", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.Validate(tt.username, tt.password); got != tt.want {
+				t.Errorf("Htpasswd.Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
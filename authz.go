@@ -0,0 +1,151 @@
+package thttp
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// RequireScopesOpt define the handler option key for specifying the scopes
+	// the principal must all have to access the route. The value must be a
+	// []string.
+	RequireScopesOpt nanux.HandlerOptName = "httpRequireScopes"
+
+	// RequireRolesOpt define the handler option key for specifying the roles
+	// allowed to access the route, the principal must have one of them. The
+	// value must be a []string.
+	RequireRolesOpt nanux.HandlerOptName = "httpRequireRoles"
+
+	// PolicyOpt define the handler option key for specifying a policy deciding
+	// if the principal can access the route. The value must be a thttp.Policy.
+	PolicyOpt nanux.HandlerOptName = "httpPolicy"
+)
+
+// authzChallenge is sent in the WWW-Authenticate header when a route with
+// authorization requirements is requested without being authenticated, the
+// authentication middlewares sending their own challenge
+const authzChallenge = `Bearer realm="Restricted"`
+
+// Policy tells if the principal can access the route requested
+type Policy func(req nanux.Request, principal Principal) bool
+
+// authorization holds the requirements of a route on the principal
+type authorization struct {
+	scopes []string
+	roles  []string
+	policy Policy
+}
+
+// newAuthorization extract the authorization requirements from the options of
+// a handler. An error is returned if an option is not of the expected type.
+func newAuthorization(opts nanux.HandlerOpts) (authz authorization, err error) {
+	var ok bool
+
+	if scopesI, exists := opts[RequireScopesOpt]; exists == true {
+		if authz.scopes, ok = scopesI.([]string); ok == false {
+			return authz, errors.New("Option associated to thttp.RequireScopesOpt is not of type []string")
+		}
+	}
+
+	if rolesI, exists := opts[RequireRolesOpt]; exists == true {
+		if authz.roles, ok = rolesI.([]string); ok == false {
+			return authz, errors.New("Option associated to thttp.RequireRolesOpt is not of type []string")
+		}
+	}
+
+	if policyI, exists := opts[PolicyOpt]; exists == true {
+		if authz.policy, ok = policyI.(Policy); ok == false {
+			return authz, errors.New("Option associated to thttp.PolicyOpt is not of type thttp.Policy")
+		}
+	}
+
+	return authz, nil
+}
+
+// required tells if the route has authorization requirements
+func (a authorization) required() bool {
+	return len(a.scopes) > 0 || len(a.roles) > 0 || a.policy != nil
+}
+
+// check the requirements against the principal of the request. The status
+// code to respond with is returned if the principal is not allowed, otherwise
+// 0 is returned.
+func (a authorization) check(req nanux.Request) int {
+	if a.required() == false {
+		return 0
+	}
+
+	principal, ok := GetPrincipal(req)
+
+	if ok == false {
+		return fasthttp.StatusUnauthorized
+	}
+
+	for _, scope := range a.scopes {
+		if containsString(principal.Scopes, scope) == false {
+			return fasthttp.StatusForbidden
+		}
+	}
+
+	if len(a.roles) > 0 {
+		allowed := false
+
+		for _, role := range a.roles {
+			if containsString(principal.Roles, role) == true {
+				allowed = true
+				break
+			}
+		}
+
+		if allowed == false {
+			return fasthttp.StatusForbidden
+		}
+	}
+
+	if a.policy != nil && a.policy(req, principal) == false {
+		return fasthttp.StatusForbidden
+	}
+
+	return 0
+}
+
+// RouteInfo describes a route handled by the transporter
+type RouteInfo struct {
+	Route  string
+	Method string
+	// Scopes required by the RequireScopesOpt option
+	Scopes []string
+	// Roles allowed by the RequireRolesOpt option
+	Roles []string
+	// Policy tells if a policy is set with the PolicyOpt option
+	Policy bool
+}
+
+// Routes return the routes handled by the transporter with their
+// authorization requirements, sorted by route and method
+func (t *Transporter) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(t.routeHandlers))
+
+	for key, rHandler := range t.routeHandlers {
+		routes = append(routes, RouteInfo{
+			Route:  key.route,
+			Method: key.method,
+			Scopes: rHandler.authz.scopes,
+			Roles:  rHandler.authz.roles,
+			Policy: rHandler.authz.policy != nil,
+		})
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Route != routes[j].Route {
+			return routes[i].Route < routes[j].Route
+		}
+
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
@@ -0,0 +1,213 @@
+package thttp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestNewAuthorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       nanux.HandlerOpts
+		wantScopes []string
+		wantRoles  []string
+		wantPolicy bool
+		wantErr    bool
+	}{
+		{
+			name: "no option",
+			opts: nanux.HandlerOpts{},
+		},
+		{
+			name: "all options",
+			opts: nanux.HandlerOpts{
+				RequireScopesOpt: []string{"orders:write"},
+				RequireRolesOpt:  []string{"admin"},
+				PolicyOpt:        Policy(func(nanux.Request, Principal) bool { return true }),
+			},
+			wantScopes: []string{"orders:write"},
+			wantRoles:  []string{"admin"},
+			wantPolicy: true,
+		},
+		{
+			name:    "scopes wrong type",
+			opts:    nanux.HandlerOpts{RequireScopesOpt: "orders:write"},
+			wantErr: true,
+		},
+		{
+			name:    "roles wrong type",
+			opts:    nanux.HandlerOpts{RequireRolesOpt: "admin"},
+			wantErr: true,
+		},
+		{
+			name:    "policy wrong type",
+			opts:    nanux.HandlerOpts{PolicyOpt: func(nanux.Request, Principal) bool { return true }},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authz, err := newAuthorization(tt.opts)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newAuthorization() err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == true {
+				return
+			}
+
+			if reflect.DeepEqual(authz.scopes, tt.wantScopes) == false {
+				t.Errorf("newAuthorization() scopes = %v, want %v", authz.scopes, tt.wantScopes)
+			}
+
+			if reflect.DeepEqual(authz.roles, tt.wantRoles) == false {
+				t.Errorf("newAuthorization() roles = %v, want %v", authz.roles, tt.wantRoles)
+			}
+
+			if (authz.policy != nil) != tt.wantPolicy {
+				t.Errorf("newAuthorization() policy set = %v, want %v", authz.policy != nil, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestAuthorization_check(t *testing.T) {
+	principal := Principal{
+		ID:     "alice",
+		Scopes: []string{"orders:read", "orders:write"},
+		Roles:  []string{"support"},
+	}
+
+	isAlice := func(_ nanux.Request, principal Principal) bool {
+		return principal.ID == "alice"
+	}
+
+	isBob := func(_ nanux.Request, principal Principal) bool {
+		return principal.ID == "bob"
+	}
+
+	tests := []struct {
+		name           string
+		authz          authorization
+		noPrincipal    bool
+		wantStatusCode int
+	}{
+		{name: "no requirement", authz: authorization{}, noPrincipal: true},
+		{name: "no principal", authz: authorization{scopes: []string{"orders:read"}}, noPrincipal: true, wantStatusCode: fasthttp.StatusUnauthorized},
+		{name: "all scopes", authz: authorization{scopes: []string{"orders:read", "orders:write"}}},
+		{name: "missing scope", authz: authorization{scopes: []string{"orders:read", "orders:delete"}}, wantStatusCode: fasthttp.StatusForbidden},
+		{name: "one of the roles", authz: authorization{roles: []string{"admin", "support"}}},
+		{name: "none of the roles", authz: authorization{roles: []string{"admin"}}, wantStatusCode: fasthttp.StatusForbidden},
+		{name: "policy allowing", authz: authorization{policy: isAlice}},
+		{name: "policy denying", authz: authorization{policy: isBob}, wantStatusCode: fasthttp.StatusForbidden},
+		{name: "policy denying with scopes and roles", authz: authorization{scopes: []string{"orders:read"}, roles: []string{"support"}, policy: isBob}, wantStatusCode: fasthttp.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := nanux.Request{M: map[string]interface{}{"principal": principal}}
+
+			if tt.noPrincipal == true {
+				req.M = make(map[string]interface{})
+			}
+
+			if statusCode := tt.authz.check(req); statusCode != tt.wantStatusCode {
+				t.Errorf("authorization.check() = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestNewRouteHandler_middlewares(t *testing.T) {
+	var calls []string
+
+	authenticate := func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			calls = append(calls, "authenticate")
+			req.M["principal"] = Principal{ID: "alice", Scopes: []string{"orders:write"}}
+
+			return fn(ctx, req)
+		}
+	}
+
+	tHandler := nanux.THandler{
+		Fn: func(nanux.Request) ([]byte, error) {
+			calls = append(calls, "handler")
+
+			return []byte("response"), nil
+		},
+		Opts: nanux.HandlerOpts{RequireScopesOpt: []string{"orders:write"}},
+	}
+
+	rHandler, err := newRouteHandler(tHandler, []nanux.Middleware{authenticate})
+
+	if err != nil {
+		t.Fatalf("newRouteHandler() err = %v", err)
+	}
+
+	httpCtx := &fasthttp.RequestCtx{}
+	resp, err := rHandler.fn(nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}})
+
+	if err != nil || string(resp) != "response" {
+		t.Errorf("routeHandler.fn() = %s, %v, want response, <nil>", resp, err)
+	}
+
+	if reflect.DeepEqual(calls, []string{"authenticate", "handler"}) == false {
+		t.Errorf("routeHandler.fn() calls = %v", calls)
+	}
+
+	// without the authentication middleware the principal is missing
+	rHandler, _ = newRouteHandler(tHandler, nil)
+	calls = nil
+	resp, err = rHandler.fn(nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}})
+
+	if err != nil || resp != nil || len(calls) > 0 {
+		t.Errorf("routeHandler.fn() = %s, %v, calls %v", resp, err, calls)
+	}
+
+	if httpCtx.Response.StatusCode() != fasthttp.StatusUnauthorized {
+		t.Errorf("routeHandler.fn() status code = %v, want 401", httpCtx.Response.StatusCode())
+	}
+
+	if challenge := string(httpCtx.Response.Header.Peek("WWW-Authenticate")); challenge != authzChallenge {
+		t.Errorf("routeHandler.fn() WWW-Authenticate = %q, want %q", challenge, authzChallenge)
+	}
+}
+
+func TestTransporter_Routes(t *testing.T) {
+	tr := New("127.0.0.1:1234", false)
+	policy := Policy(func(nanux.Request, Principal) bool { return true })
+
+	handlers := map[string]nanux.THandler{
+		"/orders": {Opts: nanux.HandlerOpts{
+			MethodsOpt:       Methods{Get: true, Post: true},
+			RequireScopesOpt: []string{"orders:read"},
+		}},
+		"/admin": {Opts: nanux.HandlerOpts{
+			MethodsOpt:      Methods{Delete: true},
+			RequireRolesOpt: []string{"admin"},
+			PolicyOpt:       policy,
+		}},
+	}
+
+	for route, tHandler := range handlers {
+		if err := tr.Handle(route, tHandler); err != nil {
+			t.Fatalf("Transporter.Handle() err = %v", err)
+		}
+	}
+
+	want := []RouteInfo{
+		{Route: "/admin", Method: "DELETE", Roles: []string{"admin"}, Policy: true},
+		{Route: "/orders", Method: "GET", Scopes: []string{"orders:read"}},
+		{Route: "/orders", Method: "POST", Scopes: []string{"orders:read"}},
+	}
+
+	if got := tr.Routes(); reflect.DeepEqual(got, want) == false {
+		t.Errorf("Transporter.Routes() = %+v, want %+v", got, want)
+	}
+}
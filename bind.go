@@ -0,0 +1,210 @@
+package thttp
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+var errBindTarget = errors.New("Bind : the destination must be a pointer to a struct")
+
+// Bind decode the request in dst, which must be a pointer to a struct, and
+// validate it (see Validate). The body is decoded according to its content
+// type: form-urlencoded, multipart or one of the codecs of the transporter
+// (JSON by default, see WithCodecs). The query string is decoded when
+// there is no body. The form and query values are matched with the `form` tag
+// of the fields, or their name.
+//
+// The errors returned are sent to the client with their status code: 400 for
+// a malformed request, 415 for an unsupported content type and 422 for a
+// ValidationError.
+func Bind(req nanux.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+
+	if v.Kind() != reflect.Ptr || v.IsNil() == true || v.Elem().Kind() != reflect.Struct {
+		return errBindTarget
+	}
+
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return err
+	}
+
+	if len(req.Data) == 0 {
+		err = bindValues(v.Elem(), "form", argsLookup(httpCtx.QueryArgs()))
+	} else {
+		err = bindBody(httpCtx, GetCodecs(req), req.Data, dst)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return Validate(dst)
+}
+
+// bindBody decode the body in dst, a pointer to a struct, according to its
+// content type. The bodies which are not forms are decoded with the codecs.
+func bindBody(httpCtx *fasthttp.RequestCtx, codecs []Codec, body []byte, dst interface{}) error {
+	v := reflect.ValueOf(dst).Elem()
+
+	switch mediaTypeOf(string(httpCtx.Request.Header.ContentType())) {
+	case "application/x-www-form-urlencoded":
+		return bindValues(v, "form", argsLookup(httpCtx.PostArgs()))
+	case "multipart/form-data":
+		form, err := httpCtx.MultipartForm()
+
+		if err != nil {
+			return &Error{Status: 400, Message: "Invalid multipart body : " + err.Error()}
+		}
+
+		return bindValues(v, "form", func(name string) []string {
+			return form.Value[name]
+		})
+	}
+
+	return decodeBody(httpCtx, codecs, body, dst)
+}
+
+// argsLookup return a function returning the values of an argument
+func argsLookup(args *fasthttp.Args) func(name string) []string {
+	return func(name string) []string {
+		var values []string
+
+		for _, value := range args.PeekMulti(name) {
+			values = append(values, string(value))
+		}
+
+		return values
+	}
+}
+
+// bindValues set the fields of the struct having the tag with the values
+// returned by lookup for the name in the tag. The name of the fields without
+// the tag is used for the form tag.
+func bindValues(v reflect.Value, tag string, lookup func(name string) []string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get(tag)
+
+		if sf.PkgPath != "" || name == "-" {
+			continue
+		}
+
+		if name == "" {
+			if tag != "form" {
+				continue
+			}
+
+			name = sf.Name
+		}
+
+		values := lookup(name)
+
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setField(v.Field(i), values); err != nil {
+			return &Error{Status: 400, Message: "Invalid value for " + tag + " " + name}
+		}
+	}
+
+	return nil
+}
+
+// setField set the field, which can be a pointer or a slice, with the values
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+
+		if err := setField(elem.Elem(), values); err != nil {
+			return err
+		}
+
+		field.Set(elem)
+
+		return nil
+	}
+
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+
+		field.Set(slice)
+
+		return nil
+	}
+
+	return setValue(field, values[0])
+}
+
+// setValue parse the string according to the kind of the field
+func setValue(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		field.SetInt(int64(d))
+
+		return err
+	}
+
+	if field.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, value)
+		field.Set(reflect.ValueOf(t))
+
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(f)
+	default:
+		return errors.New("Bind : unsupported field type " + field.Type().String())
+	}
+
+	return nil
+}
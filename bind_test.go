@@ -0,0 +1,141 @@
+package thttp
+
+import (
+	"bytes"
+	"mime/multipart"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+type testBindTarget struct {
+	Name     string        `json:"name" form:"name" validate:"required"`
+	Quantity int           `json:"quantity" form:"quantity"`
+	Gift     bool          `json:"gift" form:"gift"`
+	Price    *float64      `json:"price" form:"price"`
+	Tags     []string      `json:"tags" form:"tag"`
+	Delay    time.Duration `json:"-" form:"delay"`
+	Ignored  string        `json:"-" form:"-"`
+}
+
+func TestBind(t *testing.T) {
+	price := 9.5
+
+	multipartBody := func() (string, []byte) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		w.WriteField("name", "book")
+		w.WriteField("tag", "a")
+		w.WriteField("tag", "b")
+		w.Close()
+
+		return w.FormDataContentType(), body.Bytes()
+	}
+
+	multipartContentType, multipartData := multipartBody()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		query       string
+		want        testBindTarget
+		wantStatus  int
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        []byte(`{"name":"book","quantity":2,"price":9.5,"tags":["a"]}`),
+			want:        testBindTarget{Name: "book", Quantity: 2, Price: &price, Tags: []string{"a"}},
+		},
+		{
+			name:        "json suffix",
+			contentType: "application/vnd.orders+json",
+			body:        []byte(`{"name":"book"}`),
+			want:        testBindTarget{Name: "book"},
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        []byte("name=book&quantity=2&gift=true&price=9.5&tag=a&tag=b&delay=1m&Ignored=x"),
+			want:        testBindTarget{Name: "book", Quantity: 2, Gift: true, Price: &price, Tags: []string{"a", "b"}, Delay: time.Minute},
+		},
+		{
+			name:        "multipart",
+			contentType: multipartContentType,
+			body:        multipartData,
+			want:        testBindTarget{Name: "book", Tags: []string{"a", "b"}},
+		},
+		{
+			name:  "query string",
+			query: "name=book&quantity=3",
+			want:  testBindTarget{Name: "book", Quantity: 3},
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        []byte(`{"name":`),
+			wantStatus:  400,
+		},
+		{
+			name:        "invalid form value",
+			contentType: "application/x-www-form-urlencoded",
+			body:        []byte("name=book&quantity=two"),
+			wantStatus:  400,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/csv",
+			body:        []byte("name\nbook"),
+			wantStatus:  415,
+		},
+		{
+			name:       "validation error",
+			query:      "quantity=3",
+			wantStatus: 422,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.SetRequestURI("/orders?" + tt.query)
+			httpCtx.Request.Header.SetContentType(tt.contentType)
+			httpCtx.Request.SetBody(tt.body)
+			req := nanux.Request{Data: tt.body, M: map[string]interface{}{"httpCtx": httpCtx}}
+
+			var got testBindTarget
+			err := Bind(req, &got)
+
+			if tt.wantStatus != 0 {
+				if err == nil || errorStatusCode(err) != tt.wantStatus {
+					t.Errorf("Bind() err = %v, want status %d", err, tt.wantStatus)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Bind() err = %v", err)
+			}
+
+			if reflect.DeepEqual(got, tt.want) == false {
+				t.Errorf("Bind() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBind_target(t *testing.T) {
+	req := nanux.Request{M: map[string]interface{}{"httpCtx": &fasthttp.RequestCtx{}}}
+	var s string
+
+	for _, dst := range []interface{}{testBindTarget{}, &s, nil} {
+		if err := Bind(req, dst); err != errBindTarget {
+			t.Errorf("Bind(%T) err = %v, want %v", dst, err, errBindTarget)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package thttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+var errNotProtoMessage = errors.New("ProtobufCodec : the value is not a proto.Message")
+
+// Codec encodes and decodes the bodies of a set of media types
+type Codec interface {
+	// ContentTypes return the media types of the codec. The first one is the
+	// content type of the responses when the client accepts any of them.
+	ContentTypes() []string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the codec of application/json, used by default
+type JSONCodec struct{}
+
+// ContentTypes return the media types of JSON
+func (JSONCodec) ContentTypes() []string {
+	return []string{"application/json"}
+}
+
+// Marshal encode the value in JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decode the JSON data in v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// XMLCodec is the codec of application/xml and text/xml
+type XMLCodec struct{}
+
+// ContentTypes return the media types of XML
+func (XMLCodec) ContentTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+// Marshal encode the value in XML
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+// Unmarshal decode the XML data in v
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// MsgPackCodec is the codec of application/msgpack. The `json` tags of the
+// fields are used when they have no `msgpack` tag.
+type MsgPackCodec struct{}
+
+// ContentTypes return the media types of MessagePack
+func (MsgPackCodec) ContentTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack"}
+}
+
+// Marshal encode the value in MessagePack
+func (MsgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decode the MessagePack data in v
+func (MsgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
+
+// CBORCodec is the codec of application/cbor. The `json` tags of the fields
+// are used when they have no `cbor` tag.
+type CBORCodec struct{}
+
+// ContentTypes return the media types of CBOR
+func (CBORCodec) ContentTypes() []string {
+	return []string{"application/cbor"}
+}
+
+// Marshal encode the value in CBOR
+func (CBORCodec) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+// Unmarshal decode the CBOR data in v
+func (CBORCodec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
+
+// ProtobufCodec is the codec of application/x-protobuf. It only encodes and
+// decodes the values implementing proto.Message.
+type ProtobufCodec struct{}
+
+// ContentTypes return the media types of protobuf
+func (ProtobufCodec) ContentTypes() []string {
+	return []string{"application/x-protobuf", "application/protobuf"}
+}
+
+// Marshal encode the message in protobuf
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+
+	if ok == false {
+		return nil, errNotProtoMessage
+	}
+
+	return proto.Marshal(m)
+}
+
+// Unmarshal decode the protobuf data in the message
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+
+	if ok == false {
+		return errNotProtoMessage
+	}
+
+	return proto.Unmarshal(data, m)
+}
+
+// WithCodecs add codecs to the JSON one used by default. A codec replaces the
+// one already handling its first media type. The codecs are injected in
+// `req.M["codecs"]` and used by Bind, Decode, Encode and the typed handlers.
+func WithCodecs(codecs ...Codec) Option {
+	return func(t *Transporter) {
+		for _, codec := range codecs {
+			t.codecs = addCodec(t.codecs, codec)
+		}
+	}
+}
+
+// addCodec add the codec to the list or replace the one handling its first
+// media type
+func addCodec(codecs []Codec, codec Codec) []Codec {
+	i := findCodec(codecs, codec.ContentTypes()[0])
+
+	if i == -1 {
+		return append(codecs, codec)
+	}
+
+	replaced := append([]Codec{}, codecs...)
+	replaced[i] = codec
+
+	return replaced
+}
+
+// GetCodecs return the codecs of the transporter injected in the nanux
+// request. If there are none, only the JSON codec is returned.
+func GetCodecs(req nanux.Request) []Codec {
+	if codecs, ok := req.M["codecs"].([]Codec); ok == true && len(codecs) > 0 {
+		return codecs
+	}
+
+	return []Codec{JSONCodec{}}
+}
+
+// Decode decode the body of the request in dst with the codec of its
+// Content-Type. A 415 status code error is returned when no codec handles it
+// and a 400 status code one when the body is malformed.
+func Decode(req nanux.Request, dst interface{}) error {
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return err
+	}
+
+	return decodeBody(httpCtx, GetCodecs(req), req.Data, dst)
+}
+
+// decodeBody decode the body with the codec of the content type of the request
+func decodeBody(httpCtx *fasthttp.RequestCtx, codecs []Codec, body []byte, dst interface{}) error {
+	mediaType := mediaTypeOf(string(httpCtx.Request.Header.ContentType()))
+	i := findCodec(codecs, mediaType)
+
+	if i == -1 {
+		return &Error{Status: 415, Message: "Unsupported content type : " + mediaType}
+	}
+
+	if err := codecs[i].Unmarshal(body, dst); err != nil {
+		return &Error{Status: 400, Message: "Invalid " + mediaType + " body : " + err.Error()}
+	}
+
+	return nil
+}
+
+// Encode encode the value with the codec negotiated from the Accept header of
+// the request and set the Content-Type of the response. A 406 status code
+// error is returned when the client accepts none of the codecs.
+func Encode(req nanux.Request, v interface{}) ([]byte, error) {
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	codec, contentType, err := negotiateCodec(httpCtx, GetCodecs(req))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeBody(httpCtx, codec, contentType, v)
+}
+
+// encodeBody encode the value with the codec and set the content type of the
+// response
+func encodeBody(httpCtx *fasthttp.RequestCtx, codec Codec, contentType string, v interface{}) ([]byte, error) {
+	body, err := codec.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpCtx.SetContentType(contentType)
+
+	return body, nil
+}
+
+// negotiateCodec return the codec, and its media type, which is the most
+// preferred by the client according to the Accept header of the request. When
+// the client gives the same weight to several media types the order of the
+// codecs is used, so the first codec is used when there is no Accept header.
+func negotiateCodec(httpCtx *fasthttp.RequestCtx, codecs []Codec) (Codec, string, error) {
+	// the response depends on the Accept header as soon as it is negotiated
+	addVary(&httpCtx.Response.Header, "Accept")
+
+	accept := string(httpCtx.Request.Header.Peek("Accept"))
+
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0], codecs[0].ContentTypes()[0], nil
+	}
+
+	var ranges []string
+	var weights []float64
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, q := parseQuality(part)
+
+		if mediaRange != "" {
+			ranges = append(ranges, strings.ToLower(mediaRange))
+			weights = append(weights, q)
+		}
+	}
+
+	var best Codec
+	bestType := ""
+	bestQ := 0.0
+
+	for _, codec := range codecs {
+		for _, mediaType := range codec.ContentTypes() {
+			if q := acceptQuality(mediaType, ranges, weights); q > bestQ {
+				best = codec
+				bestType = mediaType
+				bestQ = q
+			}
+		}
+	}
+
+	if best == nil {
+		return nil, "", &Error{Status: 406, Message: "None of the accepted media types can be produced"}
+	}
+
+	return best, bestType, nil
+}
+
+// acceptQuality return the quality given to the media type by the most
+// specific media range matching it, or 0 if none matches
+func acceptQuality(mediaType string, ranges []string, weights []float64) float64 {
+	q := 0.0
+	specificity := 0
+
+	for i, mediaRange := range ranges {
+		s := 0
+
+		switch {
+		case mediaRange == mediaType:
+			s = 3
+		case strings.HasSuffix(mediaRange, "/*") == true && strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1]) == true:
+			s = 2
+		case mediaRange == "*/*" || mediaRange == "*":
+			s = 1
+		}
+
+		if s > specificity {
+			q = weights[i]
+			specificity = s
+		}
+	}
+
+	return q
+}
+
+// findCodec return the index of the codec handling the media type, or -1 if
+// none does. A media type with a structured syntax suffix (eg:
+// application/vnd.orders+json) is handled by the codec of the suffix.
+func findCodec(codecs []Codec, mediaType string) int {
+	candidates := []string{mediaType}
+
+	if i := strings.LastIndex(mediaType, "+"); i != -1 {
+		candidates = append(candidates, "application/"+mediaType[i+1:])
+	}
+
+	for _, candidate := range candidates {
+		for i, codec := range codecs {
+			for _, contentType := range codec.ContentTypes() {
+				if contentType == candidate {
+					return i
+				}
+			}
+		}
+	}
+
+	return -1
+}
+
+// mediaTypeOf return the media type of a Content-Type header, without its
+// parameters and in lower case
+func mediaTypeOf(contentType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
@@ -0,0 +1,196 @@
+package thttp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testCodecValue struct {
+	Name     string   `json:"name" xml:"name"`
+	Quantity int      `json:"quantity" xml:"quantity"`
+	Tags     []string `json:"tags" xml:"tag"`
+}
+
+func TestCodecs(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{name: "json", codec: JSONCodec{}},
+		{name: "xml", codec: XMLCodec{}},
+		{name: "msgpack", codec: MsgPackCodec{}},
+		{name: "cbor", codec: CBORCodec{}},
+	}
+
+	value := testCodecValue{Name: "book", Quantity: 2, Tags: []string{"a", "b"}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.codec.Marshal(value)
+
+			if err != nil {
+				t.Fatalf("Codec.Marshal() err = %v", err)
+			}
+
+			var got testCodecValue
+
+			if err := tt.codec.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Codec.Unmarshal() err = %v", err)
+			}
+
+			if reflect.DeepEqual(got, value) == false {
+				t.Errorf("Codec.Unmarshal() = %v, want %v", got, value)
+			}
+		})
+	}
+
+	t.Run("protobuf", func(t *testing.T) {
+		codec := ProtobufCodec{}
+		data, err := codec.Marshal(wrapperspb.String("book"))
+
+		if err != nil {
+			t.Fatalf("ProtobufCodec.Marshal() err = %v", err)
+		}
+
+		got := &wrapperspb.StringValue{}
+
+		if err := codec.Unmarshal(data, got); err != nil || got.GetValue() != "book" {
+			t.Errorf("ProtobufCodec.Unmarshal() = %v, err = %v", got, err)
+		}
+
+		if _, err := codec.Marshal(value); err != errNotProtoMessage {
+			t.Errorf("ProtobufCodec.Marshal() err = %v, want %v", err, errNotProtoMessage)
+		}
+	})
+}
+
+func TestWithCodecs(t *testing.T) {
+	tr := New("127.0.0.1:1234", false, WithCodecs(MsgPackCodec{}, CBORCodec{}, JSONCodec{}))
+
+	want := []Codec{JSONCodec{}, MsgPackCodec{}, CBORCodec{}}
+
+	if reflect.DeepEqual(tr.codecs, want) == false {
+		t.Errorf("WithCodecs() codecs = %v, want %v", tr.codecs, want)
+	}
+}
+
+func TestNegotiateCodec(t *testing.T) {
+	codecs := []Codec{JSONCodec{}, MsgPackCodec{}, CBORCodec{}}
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantStatus      int
+	}{
+		{name: "no accept header", wantContentType: "application/json"},
+		{name: "any media type", accept: "*/*", wantContentType: "application/json"},
+		{name: "exact media type", accept: "application/cbor", wantContentType: "application/cbor"},
+		{name: "alias of a codec", accept: "application/x-msgpack", wantContentType: "application/x-msgpack"},
+		{name: "quality", accept: "application/json;q=0.5, application/msgpack", wantContentType: "application/msgpack"},
+		{name: "same quality", accept: "application/cbor, application/msgpack", wantContentType: "application/msgpack"},
+		{name: "specific range wins over wildcard", accept: "application/*, application/json;q=0", wantContentType: "application/msgpack"},
+		{name: "not acceptable", accept: "text/html", wantStatus: 406},
+		{name: "refused media type", accept: "application/json;q=0", wantStatus: 406},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("Accept", tt.accept)
+
+			_, contentType, err := negotiateCodec(httpCtx, codecs)
+
+			if tt.wantStatus != 0 {
+				if err == nil || errorStatusCode(err) != tt.wantStatus {
+					t.Errorf("negotiateCodec() err = %v, want status %d", err, tt.wantStatus)
+				}
+
+				return
+			}
+
+			if err != nil || contentType != tt.wantContentType {
+				t.Errorf("negotiateCodec() = %s, %v, want %s", contentType, err, tt.wantContentType)
+			}
+
+			if vary := string(httpCtx.Response.Header.Peek("Vary")); vary != "Accept" {
+				t.Errorf("negotiateCodec() Vary = %s, want Accept", vary)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	msgpackBody, _ := MsgPackCodec{}.Marshal(testCodecValue{Name: "book"})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        testCodecValue
+		wantStatus  int
+	}{
+		{name: "json", contentType: "application/json; charset=utf-8", body: []byte(`{"name":"book"}`), want: testCodecValue{Name: "book"}},
+		{name: "structured syntax suffix", contentType: "application/vnd.orders+json", body: []byte(`{"name":"book"}`), want: testCodecValue{Name: "book"}},
+		{name: "msgpack", contentType: "application/msgpack", body: msgpackBody, want: testCodecValue{Name: "book"}},
+		{name: "malformed body", contentType: "application/json", body: []byte(`{"name":`), wantStatus: 400},
+		{name: "codec not registered", contentType: "application/xml", body: []byte(`<a></a>`), wantStatus: 415},
+		{name: "no content type", body: []byte(`{"name":"book"}`), wantStatus: 415},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.SetContentType(tt.contentType)
+
+			req := nanux.Request{
+				Data: tt.body,
+				M:    map[string]interface{}{"httpCtx": httpCtx, "codecs": []Codec{JSONCodec{}, MsgPackCodec{}}},
+			}
+
+			var got testCodecValue
+			err := Decode(req, &got)
+
+			if tt.wantStatus != 0 {
+				if err == nil || errorStatusCode(err) != tt.wantStatus {
+					t.Errorf("Decode() err = %v, want status %d", err, tt.wantStatus)
+				}
+
+				return
+			}
+
+			if err != nil || reflect.DeepEqual(got, tt.want) == false {
+				t.Errorf("Decode() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	httpCtx := &fasthttp.RequestCtx{}
+	httpCtx.Request.Header.Set("Accept", "application/cbor")
+
+	req := nanux.Request{
+		M: map[string]interface{}{"httpCtx": httpCtx, "codecs": []Codec{JSONCodec{}, CBORCodec{}}},
+	}
+
+	body, err := Encode(req, testCodecValue{Name: "book"})
+
+	if err != nil {
+		t.Fatalf("Encode() err = %v", err)
+	}
+
+	if contentType := string(httpCtx.Response.Header.ContentType()); contentType != "application/cbor" {
+		t.Errorf("Encode() content type = %s, want application/cbor", contentType)
+	}
+
+	var got testCodecValue
+
+	if err := (CBORCodec{}).Unmarshal(body, &got); err != nil || got.Name != "book" {
+		t.Errorf("Encode() body = %v, err = %v", got, err)
+	}
+}
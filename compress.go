@@ -0,0 +1,271 @@
+package thttp
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// Content codings supported by the compression and decompression of bodies
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+)
+
+// DefaultCompressContentTypes is the list of content types compressed when no
+// list is provided in the CompressConfig
+var DefaultCompressContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// CompressConfig define the configuration of the Compress middleware
+type CompressConfig struct {
+	// Encodings are the content codings the server can use, by order of
+	// preference. Default to br, zstd and gzip.
+	Encodings []string
+	// ContentTypes are the content types of the responses which can be
+	// compressed. A pattern like "text/*" matches all the subtypes. Default to
+	// DefaultCompressContentTypes.
+	ContentTypes []string
+	// MinSize is the minimum size in bytes of a response body to be compressed.
+	// Default to 1024.
+	MinSize int
+}
+
+// Compress return a middleware which compresses the response returned by the
+// handler with the encoding negotiated from the Accept-Encoding header of the
+// request. Responses already having a Content-Encoding are sent as is.
+func Compress(cfg CompressConfig) nanux.Middleware {
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	}
+
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressContentTypes
+	}
+
+	if cfg.MinSize == 0 {
+		cfg.MinSize = 1024
+	}
+
+	c := newCompressor()
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := fn(ctx, req)
+
+			if err != nil || resp == nil {
+				return resp, err
+			}
+
+			header := &httpCtx.Response.Header
+
+			// the body has already been encoded by the handler
+			if responseHeader(req, httpCtx, "Content-Encoding") != "" {
+				return resp, nil
+			}
+
+			if matchContentType(responseHeader(req, httpCtx, "Content-Type"), cfg.ContentTypes) == false {
+				return resp, nil
+			}
+
+			// the response depends on the Accept-Encoding header as soon as it can
+			// be compressed, even if it is not for this request
+			addVary(header, "Accept-Encoding")
+
+			if len(resp) < cfg.MinSize {
+				return resp, nil
+			}
+
+			encoding := negotiateEncoding(string(httpCtx.Request.Header.Peek("Accept-Encoding")), cfg.Encodings)
+
+			if encoding == "" {
+				return resp, nil
+			}
+
+			compressed, err := c.compress(encoding, resp)
+
+			if err != nil {
+				GetLogger(req).Error().Err(err).Msgf("Compress : could not compress response with %s", encoding)
+
+				return resp, nil
+			}
+
+			// compressing does not always help on small or random bodies
+			if len(compressed) >= len(resp) {
+				return resp, nil
+			}
+
+			header.Set("Content-Encoding", encoding)
+
+			return compressed, nil
+		}
+	}
+}
+
+// negotiateEncoding return the encoding, among the available ones, which is
+// the most preferred by the client according to the Accept-Encoding header.
+// When the client gives the same weight to several encodings the order of
+// available is used. An empty string is returned if none is acceptable.
+func negotiateEncoding(acceptEncoding string, available []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	weights := make(map[string]float64)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, q := parseQuality(part)
+
+		if coding != "" {
+			weights[strings.ToLower(coding)] = q
+		}
+	}
+
+	best := ""
+	bestQ := 0.0
+
+	for _, encoding := range available {
+		q, ok := weights[encoding]
+
+		if ok == false {
+			q, ok = weights["*"]
+		}
+
+		if ok == true && q > bestQ {
+			best = encoding
+			bestQ = q
+		}
+	}
+
+	return best
+}
+
+// parseQuality split an element of an Accept like header into its value and
+// its quality. The quality is 1 if it is not specified.
+func parseQuality(part string) (value string, q float64) {
+	q = 1
+	params := strings.Split(part, ";")
+	value = strings.TrimSpace(params[0])
+
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+
+		if strings.HasPrefix(param, "q=") == false {
+			continue
+		}
+
+		parsedQ, err := strconv.ParseFloat(param[2:], 64)
+
+		if err != nil {
+			return "", 0
+		}
+
+		q = parsedQ
+	}
+
+	return
+}
+
+// addVary add the value to the Vary header if it is not already present
+func addVary(header *fasthttp.ResponseHeader, value string) {
+	vary := string(header.Peek("Vary"))
+
+	for _, v := range strings.Split(vary, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) == true {
+			return
+		}
+	}
+
+	if vary == "" {
+		header.Set("Vary", value)
+
+		return
+	}
+
+	header.Set("Vary", vary+", "+value)
+}
+
+// compressor encodes bodies with the supported content codings. Writers are
+// pooled because their allocation is expensive.
+type compressor struct {
+	gzipPool   sync.Pool
+	brotliPool sync.Pool
+	zstd       *zstd.Encoder
+}
+
+func newCompressor() *compressor {
+	// zstd.NewWriter only fails on invalid options
+	zstdEncoder, _ := zstd.NewWriter(nil)
+
+	return &compressor{
+		gzipPool: sync.Pool{New: func() interface{} {
+			return gzip.NewWriter(nil)
+		}},
+		brotliPool: sync.Pool{New: func() interface{} {
+			return brotli.NewWriter(nil)
+		}},
+		zstd: zstdEncoder,
+	}
+}
+
+func (c *compressor) compress(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch encoding {
+	case EncodingZstd:
+		return c.zstd.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+
+	case EncodingGzip:
+		w := c.gzipPool.Get().(*gzip.Writer)
+		defer c.gzipPool.Put(w)
+
+		w.Reset(&buf)
+
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+	case EncodingBrotli:
+		w := c.brotliPool.Get().(*brotli.Writer)
+		defer c.brotliPool.Put(w)
+
+		w.Reset(&buf)
+
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.New("Unsupported content encoding: " + encoding)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,195 @@
+package thttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	available := []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{name: "no header", acceptEncoding: "", want: ""},
+		{name: "single encoding", acceptEncoding: "gzip", want: EncodingGzip},
+		{name: "server preference on equal weights", acceptEncoding: "gzip, deflate, br", want: EncodingBrotli},
+		{name: "client weights", acceptEncoding: "br;q=0.5, gzip;q=0.8", want: EncodingGzip},
+		{name: "refused encoding", acceptEncoding: "br;q=0, gzip", want: EncodingGzip},
+		{name: "wildcard", acceptEncoding: "*", want: EncodingBrotli},
+		{name: "wildcard with refused encodings", acceptEncoding: "br;q=0, zstd;q=0, *", want: EncodingGzip},
+		{name: "unsupported encoding", acceptEncoding: "deflate", want: ""},
+		{name: "invalid quality", acceptEncoding: "gzip;q=abc", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiateEncoding(tt.acceptEncoding, available); got != tt.want {
+				t.Errorf("negotiateEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	largeBody := []byte(strings.Repeat(`{"id":1,"name":"order"},`, 100))
+
+	tests := []struct {
+		name                string
+		cfg                 CompressConfig
+		acceptEncoding      string
+		contentType         string
+		contentEncoding     string
+		body                []byte
+		wantContentEncoding string
+		wantVary            string
+	}{
+		{
+			name:                "gzip",
+			acceptEncoding:      "gzip",
+			contentType:         "application/json",
+			body:                largeBody,
+			wantContentEncoding: EncodingGzip,
+			wantVary:            "Accept-Encoding",
+		},
+		{
+			name:                "brotli",
+			acceptEncoding:      "gzip, br",
+			contentType:         "application/json; charset=utf-8",
+			body:                largeBody,
+			wantContentEncoding: EncodingBrotli,
+			wantVary:            "Accept-Encoding",
+		},
+		{
+			name:                "zstd",
+			acceptEncoding:      "zstd",
+			contentType:         "text/html",
+			body:                largeBody,
+			wantContentEncoding: EncodingZstd,
+			wantVary:            "Accept-Encoding",
+		},
+		{
+			name:           "body smaller than the minimum size",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           []byte(`{"id":1}`),
+			wantVary:       "Accept-Encoding",
+		},
+		{
+			name:           "content type not allowed",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			body:           largeBody,
+		},
+		{
+			name:                "body already compressed",
+			acceptEncoding:      "gzip",
+			contentType:         "application/json",
+			contentEncoding:     EncodingBrotli,
+			body:                largeBody,
+			wantContentEncoding: EncodingBrotli,
+		},
+		{
+			name:        "client without accept encoding",
+			contentType: "application/json",
+			body:        largeBody,
+			wantVary:    "Accept-Encoding",
+		},
+		{
+			name:                "custom configuration",
+			cfg:                 CompressConfig{Encodings: []string{EncodingGzip}, ContentTypes: []string{"image/png"}, MinSize: 100},
+			acceptEncoding:      "br, gzip",
+			contentType:         "image/png",
+			body:                []byte(strings.Repeat("a", 200)),
+			wantContentEncoding: EncodingGzip,
+			wantVary:            "Accept-Encoding",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+
+			fn := func(*interface{}, nanux.Request) ([]byte, error) {
+				httpCtx.Response.Header.SetContentType(tt.contentType)
+
+				if tt.contentEncoding != "" {
+					httpCtx.Response.Header.Set("Content-Encoding", tt.contentEncoding)
+				}
+
+				return tt.body, nil
+			}
+
+			res, err := Compress(tt.cfg)(fn)(nil, req)
+
+			if err != nil {
+				t.Fatalf("Compress() - error occured when calling handler - %s", err)
+			}
+
+			gotEncoding := string(httpCtx.Response.Header.Peek("Content-Encoding"))
+
+			if gotEncoding != tt.wantContentEncoding {
+				t.Errorf("Compress() - Content-Encoding = %q, want %q", gotEncoding, tt.wantContentEncoding)
+			}
+
+			if gotVary := string(httpCtx.Response.Header.Peek("Vary")); gotVary != tt.wantVary {
+				t.Errorf("Compress() - Vary = %q, want %q", gotVary, tt.wantVary)
+			}
+
+			if tt.contentEncoding != "" {
+				gotEncoding = ""
+			}
+
+			decoded, err := decodeTestBody(gotEncoding, res)
+
+			if err != nil {
+				t.Fatalf("Compress() - could not decode response - %s", err)
+			}
+
+			if bytes.Equal(decoded, tt.body) == false {
+				t.Errorf("Compress() - decoded response = %s, want %s", decoded, tt.body)
+			}
+		})
+	}
+}
+
+func decodeTestBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(body))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return ioutil.ReadAll(r)
+
+	case EncodingBrotli:
+		return ioutil.ReadAll(brotli.NewReader(bytes.NewReader(body)))
+
+	case EncodingZstd:
+		d, err := zstd.NewReader(nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer d.Close()
+
+		return d.DecodeAll(body, nil)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,193 @@
+package thttp
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// ConcurrencyConfig define the configuration of the ConcurrencyLimit middleware
+type ConcurrencyConfig struct {
+	// Limit is the maximum number of requests processed at the same time. In
+	// adaptive mode it is the initial limit. Default to 100.
+	Limit int
+	// QueueSize is the maximum number of requests waiting for a slot. Default
+	// to Limit.
+	QueueSize int
+	// QueueTimeout is the maximum duration a request waits for a slot. When it
+	// is 0, requests are rejected as soon as the limit is reached.
+	QueueTimeout time.Duration
+
+	// Adaptive enables the adaptation of the limit to the latency of the
+	// handlers (AIMD): the limit is increased by 1/limit for each request
+	// faster than TargetLatency and multiplied by Backoff for each slower one.
+	Adaptive bool
+	// MinLimit is the minimum limit in adaptive mode. Default to 1.
+	MinLimit int
+	// MaxLimit is the maximum limit in adaptive mode. Default to 10 times Limit.
+	MaxLimit int
+	// TargetLatency is the latency above which the limit is decreased. Default
+	// to 100ms.
+	TargetLatency time.Duration
+	// Backoff is the factor applied to the limit when the latency is above the
+	// target. Default to 0.9.
+	Backoff float64
+}
+
+// ConcurrencyLimit return a middleware limiting the number of requests
+// processed at the same time. When the limit is reached, requests wait in a
+// queue and are answered with a 503 status code if they can not be processed
+// in time. The limit is shared by all the routes using the same middleware, so
+// it can apply to a single route or to a group of routes.
+func ConcurrencyLimit(cfg ConcurrencyConfig) nanux.Middleware {
+	l := newConcurrencyLimiter(cfg)
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if l.acquire(GetContext(req)) == false {
+				httpCtx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+
+				return nil, nil
+			}
+
+			start := time.Now()
+			defer func() {
+				l.release(time.Since(start))
+			}()
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// concurrencyLimiter is a semaphore whose size can change
+type concurrencyLimiter struct {
+	cfg ConcurrencyConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	// waiters are the channels of the requests waiting for a slot, they are
+	// closed when a slot is given to the request
+	waiters list.List
+}
+
+func newConcurrencyLimiter(cfg ConcurrencyConfig) *concurrencyLimiter {
+	if cfg.Limit <= 0 {
+		cfg.Limit = 100
+	}
+
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = cfg.Limit
+	}
+
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = 10 * cfg.Limit
+	}
+
+	if cfg.TargetLatency <= 0 {
+		cfg.TargetLatency = 100 * time.Millisecond
+	}
+
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = 0.9
+	}
+
+	return &concurrencyLimiter{
+		cfg:   cfg,
+		limit: float64(cfg.Limit),
+	}
+}
+
+// acquire a slot. false is returned if no slot is available before the end of
+// the queue timeout or before the context is done.
+func (l *concurrencyLimiter) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+
+	if l.inFlight < int(l.limit) {
+		l.inFlight++
+		l.mu.Unlock()
+
+		return true
+	}
+
+	if l.cfg.QueueTimeout <= 0 || l.waiters.Len() >= l.cfg.QueueSize {
+		l.mu.Unlock()
+
+		return false
+	}
+
+	ready := make(chan struct{})
+	elem := l.waiters.PushBack(ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// the slot may have been given while the lock was being acquired
+	select {
+	case <-ready:
+		return true
+	default:
+	}
+
+	l.waiters.Remove(elem)
+
+	return false
+}
+
+// release the slot of a request which took the specified duration and give it
+// to the waiting requests if the limit allows it
+func (l *concurrencyLimiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.Adaptive == true {
+		l.adapt(latency)
+	}
+
+	l.inFlight--
+
+	for l.inFlight < int(l.limit) && l.waiters.Len() > 0 {
+		elem := l.waiters.Front()
+		l.waiters.Remove(elem)
+		close(elem.Value.(chan struct{}))
+		l.inFlight++
+	}
+}
+
+// adapt the limit to the latency of a request
+func (l *concurrencyLimiter) adapt(latency time.Duration) {
+	if latency > l.cfg.TargetLatency {
+		l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.Backoff)
+
+		return
+	}
+
+	l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+}
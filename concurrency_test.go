@@ -0,0 +1,175 @@
+package thttp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestConcurrencyLimiter_acquire(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         ConcurrencyConfig
+		releaseIn   time.Duration
+		ctxTimeout  time.Duration
+		wantAcquire bool
+	}{
+		{
+			name:        "limit reached without queue",
+			cfg:         ConcurrencyConfig{Limit: 1},
+			releaseIn:   10 * time.Millisecond,
+			wantAcquire: false,
+		},
+		{
+			name:        "slot released while waiting",
+			cfg:         ConcurrencyConfig{Limit: 1, QueueTimeout: 100 * time.Millisecond},
+			releaseIn:   10 * time.Millisecond,
+			wantAcquire: true,
+		},
+		{
+			name:        "slot not released in time",
+			cfg:         ConcurrencyConfig{Limit: 1, QueueTimeout: 10 * time.Millisecond},
+			releaseIn:   100 * time.Millisecond,
+			wantAcquire: false,
+		},
+		{
+			name:        "context done while waiting",
+			cfg:         ConcurrencyConfig{Limit: 1, QueueTimeout: 100 * time.Millisecond},
+			releaseIn:   100 * time.Millisecond,
+			ctxTimeout:  10 * time.Millisecond,
+			wantAcquire: false,
+		},
+		{
+			name:        "queue full",
+			cfg:         ConcurrencyConfig{Limit: 1, QueueSize: -1, QueueTimeout: 100 * time.Millisecond},
+			releaseIn:   10 * time.Millisecond,
+			wantAcquire: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newConcurrencyLimiter(tt.cfg)
+
+			if l.acquire(context.Background()) == false {
+				t.Fatal("concurrencyLimiter.acquire() must give a slot when the limit is not reached")
+			}
+
+			releaseIn := tt.releaseIn
+
+			go func() {
+				time.Sleep(releaseIn)
+				l.release(0)
+			}()
+
+			ctx := context.Background()
+
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+
+			if got := l.acquire(ctx); got != tt.wantAcquire {
+				t.Errorf("concurrencyLimiter.acquire() = %v, want %v", got, tt.wantAcquire)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimiter_adapt(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     float64
+		latency   time.Duration
+		wantLimit float64
+	}{
+		{name: "latency below the target", limit: 4, latency: time.Millisecond, wantLimit: 4.25},
+		{name: "latency above the target", limit: 10, latency: time.Second, wantLimit: 5},
+		{name: "limit not above the maximum", limit: 20, latency: time.Millisecond, wantLimit: 20},
+		{name: "limit not below the minimum", limit: 3, latency: time.Second, wantLimit: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newConcurrencyLimiter(ConcurrencyConfig{
+				Limit:         10,
+				Adaptive:      true,
+				MinLimit:      2,
+				MaxLimit:      20,
+				TargetLatency: 100 * time.Millisecond,
+				Backoff:       0.5,
+			})
+			l.limit = tt.limit
+
+			l.adapt(tt.latency)
+
+			if l.limit != tt.wantLimit {
+				t.Errorf("concurrencyLimiter.adapt() limit = %v, want %v", l.limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimiter_adaptDefaultTarget(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyConfig{Limit: 10, Adaptive: true})
+
+	l.adapt(time.Millisecond)
+
+	if l.limit <= 10 {
+		t.Errorf("concurrencyLimiter.adapt() limit = %v, a fast request must increase it with the default target latency", l.limit)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	started := make(chan bool)
+	finish := make(chan bool)
+
+	mw := ConcurrencyLimit(ConcurrencyConfig{Limit: 1})
+
+	slowFn := mw(func(*interface{}, nanux.Request) ([]byte, error) {
+		started <- true
+		<-finish
+
+		return []byte("slow"), nil
+	})
+
+	fastFn := mw(func(*interface{}, nanux.Request) ([]byte, error) {
+		return []byte("fast"), nil
+	})
+
+	newReq := func() (*fasthttp.RequestCtx, nanux.Request) {
+		httpCtx := &fasthttp.RequestCtx{}
+
+		return httpCtx, nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+	}
+
+	go func() {
+		_, req := newReq()
+		slowFn(nil, req)
+	}()
+
+	<-started
+
+	httpCtx, req := newReq()
+	res, err := fastFn(nil, req)
+
+	if err != nil || res != nil || httpCtx.Response.StatusCode() != fasthttp.StatusServiceUnavailable {
+		t.Errorf("ConcurrencyLimit() - request must be rejected with 503 when the limit is reached, got %d", httpCtx.Response.StatusCode())
+	}
+
+	finish <- true
+
+	// wait to let time to the slow handler to release its slot
+	time.Sleep(10 * time.Millisecond)
+
+	httpCtx, req = newReq()
+	res, err = fastFn(nil, req)
+
+	if err != nil || string(res) != "fast" {
+		t.Errorf("ConcurrencyLimit() - request must be processed once the slot is released, got %s, %v", res, err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package thttp
+
+import "net"
+
+// connClosed can not check the connections on this platform, so the
+// disconnections of the clients are not detected
+func connClosed(conn net.Conn) (closed bool, ok bool) {
+	return false, false
+}
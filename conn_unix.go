@@ -0,0 +1,57 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package thttp
+
+import (
+	"crypto/tls"
+	"net"
+	"syscall"
+)
+
+// connClosed tell if the peer of the connection has closed it, by peeking at
+// its socket without blocking nor consuming the data. The second value is
+// false when the connection can not be checked (eg: not a socket).
+func connClosed(conn net.Conn) (closed bool, ok bool) {
+	if tlsConn, isTLS := conn.(*tls.Conn); isTLS == true {
+		conn = tlsConn.NetConn()
+	}
+
+	sc, isSyscallConn := conn.(syscall.Conn)
+
+	if isSyscallConn == false {
+		return false, false
+	}
+
+	rawConn, err := sc.SyscallConn()
+
+	if err != nil {
+		return false, false
+	}
+
+	var buf [1]byte
+
+	err = rawConn.Control(func(fd uintptr) {
+		var n int
+		var recvErr error
+
+		n, _, recvErr = syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+
+		switch {
+		case recvErr == syscall.EAGAIN || recvErr == syscall.EWOULDBLOCK || recvErr == syscall.EINTR:
+			// no data available, the connection is still open
+		case recvErr != nil:
+			closed = true
+		case n == 0:
+			// end of file, the peer has closed the connection
+			closed = true
+		}
+	})
+
+	if err != nil {
+		// the connection is already closed on our side
+		return true, true
+	}
+
+	return closed, true
+}
@@ -0,0 +1,92 @@
+package thttp
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RequestTimeoutHeader is the header in which clients can send the maximum
+// duration in seconds they will wait for the response (eg: "2.5"). It can only
+// shorten the timeout of the route.
+const RequestTimeoutHeader = "Request-Timeout"
+
+// disconnectPollInterval is the interval at which the connection of a request
+// is checked to detect that the client has disconnected
+const disconnectPollInterval = 50 * time.Millisecond
+
+// requestContext return the context of the request. It is cancelled when the
+// transporter is closed, when the handler returns, when the client disconnects
+// and when the timeout of the route or the one sent by the client is reached.
+func (t *Transporter) requestContext(ctx *fasthttp.RequestCtx, rHandler routeHandler) (context.Context, context.CancelFunc) {
+	timeout := rHandler.timeout
+
+	if clientTimeout, ok := parseRequestTimeout(ctx.Request.Header.Peek(RequestTimeoutHeader)); ok == true {
+		if timeout <= 0 || clientTimeout < timeout {
+			timeout = clientTimeout
+		}
+	}
+
+	var reqCtx context.Context
+	var cancel context.CancelFunc
+
+	if timeout > 0 {
+		reqCtx, cancel = context.WithTimeout(t.ctx, timeout)
+	} else {
+		reqCtx, cancel = context.WithCancel(t.ctx)
+	}
+
+	watchDisconnect(reqCtx, cancel, ctx.Conn())
+
+	return reqCtx, cancel
+}
+
+// watchDisconnect cancel the context when the client closes the connection.
+// fasthttp does not notify the handlers of the disconnections, so the
+// connection is polled until the context is done, without consuming its data.
+// Nothing is watched on the connections which can not be polled (see
+// connClosed).
+func watchDisconnect(ctx context.Context, cancel context.CancelFunc, conn net.Conn) {
+	if _, ok := connClosed(conn); ok == false {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(disconnectPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if closed, _ := connClosed(conn); closed == true {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// parseRequestTimeout parse the value of the RequestTimeoutHeader. false is
+// returned if the value is missing, is not a positive number of seconds or
+// does not fit in a time.Duration.
+func parseRequestTimeout(value []byte) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseFloat(string(value), 64)
+
+	// the comparisons are false for NaN
+	if err != nil || (seconds > 0) == false || seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		return 0, false
+	}
+
+	return time.Duration(seconds * float64(time.Second)), true
+}
@@ -0,0 +1,135 @@
+package thttp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantTimeout time.Duration
+		wantOK      bool
+	}{
+		{name: "missing", value: "", wantOK: false},
+		{name: "seconds", value: "2", wantTimeout: 2 * time.Second, wantOK: true},
+		{name: "fraction of seconds", value: "0.25", wantTimeout: 250 * time.Millisecond, wantOK: true},
+		{name: "zero", value: "0", wantOK: false},
+		{name: "negative", value: "-1", wantOK: false},
+		{name: "not a number", value: "1s", wantOK: false},
+		{name: "NaN", value: "NaN", wantOK: false},
+		{name: "infinite", value: "Inf", wantOK: false},
+		{name: "overflowing duration", value: "1e10", wantOK: false},
+		{name: "huge value", value: "1e300", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTimeout, gotOK := parseRequestTimeout([]byte(tt.value))
+
+			if gotTimeout != tt.wantTimeout || gotOK != tt.wantOK {
+				t.Errorf("parseRequestTimeout() = %v, %v, want %v, %v", gotTimeout, gotOK, tt.wantTimeout, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTransporter_requestContext(t *testing.T) {
+	tests := []struct {
+		name          string
+		routeTimeout  time.Duration
+		clientTimeout string
+		wantDeadline  bool
+		wantTimeout   time.Duration
+	}{
+		{name: "no timeout", wantDeadline: false},
+		{name: "route timeout", routeTimeout: time.Minute, wantDeadline: true, wantTimeout: time.Minute},
+		{name: "client timeout", clientTimeout: "30", wantDeadline: true, wantTimeout: 30 * time.Second},
+		{name: "client timeout shorter than the route one", routeTimeout: time.Minute, clientTimeout: "30", wantDeadline: true, wantTimeout: 30 * time.Second},
+		{name: "client timeout longer than the route one", routeTimeout: time.Minute, clientTimeout: "120", wantDeadline: true, wantTimeout: time.Minute},
+		{name: "invalid client timeout", routeTimeout: time.Minute, clientTimeout: "abc", wantDeadline: true, wantTimeout: time.Minute},
+		{name: "overflowing client timeout", routeTimeout: time.Minute, clientTimeout: "1e300", wantDeadline: true, wantTimeout: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := New("127.0.0.1:1234", false)
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set(RequestTimeoutHeader, tt.clientTimeout)
+
+			start := time.Now()
+			ctx, cancel := tr.requestContext(httpCtx, routeHandler{timeout: tt.routeTimeout})
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+
+			if ok != tt.wantDeadline {
+				t.Fatalf("Transporter.requestContext() has deadline = %v, want %v", ok, tt.wantDeadline)
+			}
+
+			if ok == true && (deadline.Before(start.Add(tt.wantTimeout)) || deadline.After(time.Now().Add(tt.wantTimeout))) {
+				t.Errorf("Transporter.requestContext() deadline = %v, want in %v", deadline.Sub(start), tt.wantTimeout)
+			}
+
+			tr.cancel()
+
+			if ctx.Err() != context.Canceled {
+				t.Errorf("Transporter.requestContext() must be cancelled with the transporter")
+			}
+		})
+	}
+}
+
+func TestConnClosed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := ln.Accept()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer server.Close()
+
+	if closed, ok := connClosed(server); closed == true || ok == false {
+		t.Fatalf("connClosed() = %v, %v, want false, true", closed, ok)
+	}
+
+	// the pending data must not be consumed nor be seen as a disconnection
+	client.Write([]byte("x"))
+	time.Sleep(10 * time.Millisecond)
+
+	if closed, _ := connClosed(server); closed == true {
+		t.Errorf("connClosed() = true with pending data")
+	}
+
+	buf := make([]byte, 1)
+
+	if n, _ := server.Read(buf); n != 1 || buf[0] != 'x' {
+		t.Errorf("connClosed() must not consume the data")
+	}
+
+	client.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	if closed, _ := connClosed(server); closed == false {
+		t.Errorf("connClosed() = false once the client has closed the connection")
+	}
+}
@@ -0,0 +1,268 @@
+package thttp
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// CSRFExemptOpt define the handler option key for disabling the CSRF
+// protection on a route (eg: a webhook). The value must be a bool.
+const CSRFExemptOpt nanux.HandlerOptName = "httpCSRFExempt"
+
+// csrfSessionKey is the key of the token in the session
+const csrfSessionKey = "csrfToken"
+
+var (
+	errCSRFNoSession = errors.New("CSRF : the Sessions middleware must be called before the CSRF middleware")
+	errCSRFOrigin    = errors.New("CSRF : origin not allowed")
+	errCSRFToken     = errors.New("CSRF : missing or invalid token")
+)
+
+// CSRFConfig define the configuration of the CSRF middleware
+type CSRFConfig struct {
+	// Session keeps the token in the session (synchronizer token) instead of a
+	// cookie (double submit cookie). The Sessions middleware must then be
+	// called before the CSRF middleware.
+	Session bool
+	// Header containing the token. Default to "X-CSRF-Token".
+	Header string
+	// Field is the name of the form field containing the token. Default to
+	// "csrf_token".
+	Field string
+	// Cookie is the name of the cookie containing the token in double submit
+	// mode. Default to "csrf_token".
+	Cookie string
+	// CookiePath is the path of the cookie. Default to "/".
+	CookiePath string
+	// CookieDomain is the domain of the cookie
+	CookieDomain string
+	// Secure tells the browsers to only send the cookie over https
+	Secure bool
+	// TrustedOrigins are the origins, besides the one of the request, allowed
+	// to send unsafe requests (eg: "https://admin.example.com")
+	TrustedOrigins []string
+}
+
+// CSRF return a middleware protecting the routes against cross-site request
+// forgery. The token is injected in `req.M["csrfToken"]`, and can be retrieved
+// with GetCSRFToken, so that it can be added to the forms. Requests with an
+// unsafe method must send it back in the header or the form field of the
+// config, and their Origin or Referer header, when present, must match the host
+// of the request or a trusted origin. Other requests are answered with a 403
+// status code. A route can opt out with the CSRFExemptOpt handler option.
+func CSRF(cfg CSRFConfig) nanux.Middleware {
+	if cfg.Header == "" {
+		cfg.Header = "X-CSRF-Token"
+	}
+
+	if cfg.Field == "" {
+		cfg.Field = "csrf_token"
+	}
+
+	if cfg.Cookie == "" {
+		cfg.Cookie = "csrf_token"
+	}
+
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			if exempt, _ := GetHandlerOpts(req)[CSRFExemptOpt].(bool); exempt == true {
+				return fn(ctx, req)
+			}
+
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			var token string
+
+			if cfg.Session == true {
+				session, ok := GetSession(req)
+
+				if ok == false {
+					return nil, errCSRFNoSession
+				}
+
+				token, _ = session.Get(csrfSessionKey).(string)
+				req.M["csrfToken"] = csrfSessionToken{session: session}
+			} else {
+				if token, err = csrfCookieToken(httpCtx, cfg); err != nil {
+					return nil, err
+				}
+
+				req.M["csrfToken"] = token
+			}
+
+			if isSafeMethod(httpCtx.Method()) == false {
+				if err := checkCSRF(httpCtx, GetClient(req).Host, token, cfg); err != nil {
+					GetLogger(req).Debug().Err(err).Msg("CSRF : request rejected")
+					httpCtx.SetStatusCode(fasthttp.StatusForbidden)
+
+					return nil, nil
+				}
+			}
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// GetCSRFToken return the CSRF token injected in the nanux request by the CSRF
+// middleware. In session mode, the token is created and stored in the session
+// on the first call, so that the visitors which are never given a form do not
+// get a session cookie.
+func GetCSRFToken(req nanux.Request) string {
+	switch token := req.M["csrfToken"].(type) {
+	case string:
+		return token
+	case csrfSessionToken:
+		value, err := token.get()
+
+		if err != nil {
+			GetLogger(req).Error().Err(err).Msg("GetCSRFToken : could not create the token")
+		}
+
+		return value
+	}
+
+	return ""
+}
+
+// csrfSessionToken is the token of a session in session mode
+type csrfSessionToken struct {
+	session *Session
+}
+
+// get return the token of the session. A new token is created and stored in
+// the session if it does not have one yet.
+func (t csrfSessionToken) get() (string, error) {
+	if token, _ := t.session.Get(csrfSessionKey).(string); token != "" {
+		return token, nil
+	}
+
+	token, err := newCSRFToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	t.session.Set(csrfSessionKey, token)
+
+	return token, nil
+}
+
+// csrfCookieToken return the token of the client from the cookie. A new token
+// is created if the client does not have one yet.
+func csrfCookieToken(httpCtx *fasthttp.RequestCtx, cfg CSRFConfig) (string, error) {
+	if token := string(httpCtx.Request.Header.Cookie(cfg.Cookie)); token != "" {
+		return token, nil
+	}
+
+	token, err := newCSRFToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	// the cookie is readable by the scripts of the page so that they can send
+	// the token in the header
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+
+	cookie.SetKey(cfg.Cookie)
+	cookie.SetValue(token)
+	cookie.SetPath(cfg.CookiePath)
+	cookie.SetDomain(cfg.CookieDomain)
+	cookie.SetSecure(cfg.Secure)
+	cookie.SetSameSite(fasthttp.CookieSameSiteLaxMode)
+	httpCtx.Response.Header.SetCookie(cookie)
+
+	return token, nil
+}
+
+// checkCSRF check the origin of the request, whose host is specified, and the
+// token it sent. The request is rejected if the client has no token.
+func checkCSRF(httpCtx *fasthttp.RequestCtx, host, token string, cfg CSRFConfig) error {
+	origin := string(httpCtx.Request.Header.Peek("Origin"))
+
+	if origin == "" {
+		origin = string(httpCtx.Request.Header.Referer())
+	}
+
+	if origin != "" && allowedOrigin(origin, host, cfg.TrustedOrigins) == false {
+		return errCSRFOrigin
+	}
+
+	sent := httpCtx.Request.Header.Peek(cfg.Header)
+
+	if len(sent) == 0 {
+		sent = httpCtx.PostArgs().Peek(cfg.Field)
+	}
+
+	if len(sent) == 0 {
+		if form, err := httpCtx.MultipartForm(); err == nil && len(form.Value[cfg.Field]) > 0 {
+			sent = []byte(form.Value[cfg.Field][0])
+		}
+	}
+
+	if len(sent) == 0 || token == "" || subtle.ConstantTimeCompare(sent, []byte(token)) == 0 {
+		return errCSRFToken
+	}
+
+	return nil
+}
+
+// allowedOrigin tells if the origin, or the referer, has the host of the
+// request or is one of the trusted origins
+func allowedOrigin(origin, host string, trusted []string) bool {
+	u, err := url.Parse(origin)
+
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, host) == true {
+		return true
+	}
+
+	for _, t := range trusted {
+		if strings.EqualFold(u.Scheme+"://"+u.Host, t) == true {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSafeMethod tells if the method does not change the state of the server
+func isSafeMethod(method []byte) bool {
+	switch string(method) {
+	case fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodOptions, fasthttp.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
+// newCSRFToken return a random token
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,242 @@
+package thttp
+
+import (
+	"testing"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCSRF(t *testing.T) {
+	token := "0123456789abcdef"
+
+	tests := []struct {
+		name           string
+		cfg            CSRFConfig
+		method         string
+		headers        map[string]string
+		cookie         string
+		sessionToken   string
+		form           string
+		opts           nanux.HandlerOpts
+		wantStatusCode int
+	}{
+		{
+			name:           "safe method without token",
+			method:         "GET",
+			wantStatusCode: 200,
+		},
+		{
+			name:           "token in the header",
+			method:         "POST",
+			cookie:         token,
+			headers:        map[string]string{"X-CSRF-Token": token},
+			wantStatusCode: 200,
+		},
+		{
+			name:           "token in the form",
+			method:         "POST",
+			cookie:         token,
+			form:           "csrf_token=" + token,
+			wantStatusCode: 200,
+		},
+		{
+			name:           "custom header and field",
+			cfg:            CSRFConfig{Header: "X-XSRF-Token", Field: "_csrf"},
+			method:         "POST",
+			cookie:         token,
+			form:           "_csrf=" + token,
+			wantStatusCode: 200,
+		},
+		{
+			name:           "missing token",
+			method:         "POST",
+			cookie:         token,
+			wantStatusCode: 403,
+		},
+		{
+			name:           "token not matching the cookie",
+			method:         "DELETE",
+			cookie:         token,
+			headers:        map[string]string{"X-CSRF-Token": "other"},
+			wantStatusCode: 403,
+		},
+		{
+			name:           "missing cookie",
+			method:         "POST",
+			headers:        map[string]string{"X-CSRF-Token": token},
+			wantStatusCode: 403,
+		},
+		{
+			name:           "same origin",
+			method:         "POST",
+			cookie:         token,
+			headers:        map[string]string{"X-CSRF-Token": token, "Origin": "https://example.com"},
+			wantStatusCode: 200,
+		},
+		{
+			name:           "other origin",
+			method:         "POST",
+			cookie:         token,
+			headers:        map[string]string{"X-CSRF-Token": token, "Origin": "https://evil.com"},
+			wantStatusCode: 403,
+		},
+		{
+			name:           "null origin",
+			method:         "POST",
+			cookie:         token,
+			headers:        map[string]string{"X-CSRF-Token": token, "Origin": "null"},
+			wantStatusCode: 403,
+		},
+		{
+			name:           "trusted origin",
+			cfg:            CSRFConfig{TrustedOrigins: []string{"https://admin.example.com"}},
+			method:         "POST",
+			cookie:         token,
+			headers:        map[string]string{"X-CSRF-Token": token, "Origin": "https://admin.example.com"},
+			wantStatusCode: 200,
+		},
+		{
+			name:           "referer of another site",
+			method:         "POST",
+			cookie:         token,
+			headers:        map[string]string{"X-CSRF-Token": token, "Referer": "https://evil.com/form"},
+			wantStatusCode: 403,
+		},
+		{
+			name:           "referer of the same site",
+			method:         "POST",
+			cookie:         token,
+			headers:        map[string]string{"X-CSRF-Token": token, "Referer": "https://example.com/form"},
+			wantStatusCode: 200,
+		},
+		{
+			name:           "exempted route",
+			method:         "POST",
+			opts:           nanux.HandlerOpts{CSRFExemptOpt: true},
+			wantStatusCode: 200,
+		},
+		{
+			name:           "token in the session",
+			cfg:            CSRFConfig{Session: true},
+			method:         "POST",
+			sessionToken:   token,
+			headers:        map[string]string{"X-CSRF-Token": token},
+			wantStatusCode: 200,
+		},
+		{
+			name:           "session without token",
+			cfg:            CSRFConfig{Session: true},
+			method:         "POST",
+			cookie:         "other",
+			headers:        map[string]string{"X-CSRF-Token": ""},
+			form:           "csrf_token=",
+			wantStatusCode: 403,
+		},
+		{
+			name:           "token not matching the session",
+			cfg:            CSRFConfig{Session: true},
+			method:         "POST",
+			sessionToken:   token,
+			cookie:         "other",
+			headers:        map[string]string{"X-CSRF-Token": "other"},
+			wantStatusCode: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.SetMethod(tt.method)
+			httpCtx.Request.Header.SetHost("example.com")
+
+			for name, value := range tt.headers {
+				httpCtx.Request.Header.Set(name, value)
+			}
+
+			if tt.cookie != "" {
+				httpCtx.Request.Header.SetCookie("csrf_token", tt.cookie)
+			}
+
+			if tt.form != "" {
+				httpCtx.Request.Header.SetContentType("application/x-www-form-urlencoded")
+				httpCtx.Request.SetBodyString(tt.form)
+			}
+
+			session := &Session{data: SessionData{Values: map[string]interface{}{}}}
+
+			if tt.sessionToken != "" {
+				session.Set(csrfSessionKey, tt.sessionToken)
+			}
+
+			req := nanux.Request{M: map[string]interface{}{
+				"httpCtx":     httpCtx,
+				"handlerOpts": tt.opts,
+				"session":     session,
+			}}
+
+			_, err := CSRF(tt.cfg)(func(_ *interface{}, req nanux.Request) ([]byte, error) {
+				if GetCSRFToken(req) == "" && tt.opts == nil {
+					t.Errorf("GetCSRFToken() must return the token")
+				}
+
+				return nil, nil
+			})(nil, req)
+
+			if err != nil {
+				t.Fatalf("CSRF() - error occured when calling handler - %s", err)
+			}
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("CSRF() - status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestCSRF_newToken(t *testing.T) {
+	t.Run("cookie", func(t *testing.T) {
+		httpCtx := &fasthttp.RequestCtx{}
+		req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+
+		CSRF(CSRFConfig{})(func(_ *interface{}, req nanux.Request) ([]byte, error) { return nil, nil })(nil, req)
+
+		cookie, ok := responseCookie(httpCtx, "csrf_token")
+
+		if ok == false || string(cookie.Value()) != GetCSRFToken(req) || cookie.HTTPOnly() == true {
+			t.Errorf("CSRF() - cookie = %s, token = %s", cookie.Value(), GetCSRFToken(req))
+		}
+	})
+
+	t.Run("session", func(t *testing.T) {
+		session := &Session{data: SessionData{Values: map[string]interface{}{}}}
+		req := nanux.Request{M: map[string]interface{}{"httpCtx": &fasthttp.RequestCtx{}, "session": session}}
+
+		CSRF(CSRFConfig{Session: true})(func(_ *interface{}, req nanux.Request) ([]byte, error) { return nil, nil })(nil, req)
+
+		if token := GetCSRFToken(req); token == "" || session.Get(csrfSessionKey) != token {
+			t.Errorf("CSRF() - session token = %v, token = %s", session.Get(csrfSessionKey), token)
+		}
+	})
+
+	t.Run("session of an anonymous visitor", func(t *testing.T) {
+		session := &Session{isNew: true, data: SessionData{Values: map[string]interface{}{}}}
+		req := nanux.Request{M: map[string]interface{}{"httpCtx": &fasthttp.RequestCtx{}, "session": session}}
+
+		CSRF(CSRFConfig{Session: true})(func(_ *interface{}, req nanux.Request) ([]byte, error) { return nil, nil })(nil, req)
+
+		if session.modified == true {
+			t.Errorf("CSRF() - the session must not be modified until the token is requested")
+		}
+	})
+
+	t.Run("session missing", func(t *testing.T) {
+		req := nanux.Request{M: map[string]interface{}{"httpCtx": &fasthttp.RequestCtx{}}}
+
+		_, err := CSRF(CSRFConfig{Session: true})(func(_ *interface{}, req nanux.Request) ([]byte, error) { return nil, nil })(nil, req)
+
+		if err != errCSRFNoSession {
+			t.Errorf("CSRF() err = %v, want %v", err, errCSRFNoSession)
+		}
+	})
+}
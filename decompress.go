@@ -0,0 +1,133 @@
+package thttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultMaxDecompressedSize is the default maximum size in bytes of a request
+// body once decompressed
+const DefaultMaxDecompressedSize = 10 * 1024 * 1024
+
+// EncodingDeflate is the deflate content coding, which is a zlib stream. It is
+// only supported for the request bodies.
+const EncodingDeflate = "deflate"
+
+var (
+	errUnsupportedEncoding = errors.New("Unsupported content encoding")
+	errBodyTooLarge        = errors.New("Request body too large")
+)
+
+// WithMaxDecompressedSize set the maximum size in bytes of a compressed request
+// body once decompressed. Requests exceeding it are answered with a 413 status
+// code. Default to DefaultMaxDecompressedSize.
+func WithMaxDecompressedSize(size int) Option {
+	return func(t *Transporter) {
+		t.maxDecompressedSize = size
+	}
+}
+
+// decompressBody decode the body of the request according to its
+// Content-Encoding header so that handlers always receive the plain body.
+// The status code to respond with is returned if the body can not be decoded,
+// otherwise 0 is returned.
+func decompressBody(ctx *fasthttp.RequestCtx, maxSize int) (statusCode int, err error) {
+	contentEncoding := string(ctx.Request.Header.Peek("Content-Encoding"))
+
+	if contentEncoding == "" {
+		return 0, nil
+	}
+
+	body := ctx.Request.Body()
+	encodings := strings.Split(contentEncoding, ",")
+
+	// encodings are listed in the order they were applied so they are decoded
+	// from the last one to the first one
+	for i := len(encodings) - 1; i >= 0; i-- {
+		body, err = decode(strings.ToLower(strings.TrimSpace(encodings[i])), body, maxSize)
+
+		switch err {
+		case nil:
+		case errUnsupportedEncoding:
+			ctx.Response.Header.Set("Accept-Encoding", "gzip, br, zstd, deflate")
+			return fasthttp.StatusUnsupportedMediaType, err
+		case errBodyTooLarge:
+			return fasthttp.StatusRequestEntityTooLarge, err
+		default:
+			return fasthttp.StatusBadRequest, err
+		}
+	}
+
+	ctx.Request.Header.Del("Content-Encoding")
+	ctx.Request.SetBody(body)
+
+	return 0, nil
+}
+
+// decode decompress the body encoded with the specified content coding. An
+// errBodyTooLarge error is returned as soon as the decoded body is bigger than
+// maxSize.
+func decode(encoding string, body []byte, maxSize int) ([]byte, error) {
+	if encoding == "identity" {
+		return body, nil
+	}
+
+	r, err := newDecoder(encoding, bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	// one more byte than the limit is read to know if the limit is exceeded
+	decoded, err := ioutil.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decoded) > maxSize {
+		return nil, errBodyTooLarge
+	}
+
+	return decoded, nil
+}
+
+// newDecoder return a reader decoding src with the specified content coding.
+// It must be closed to release its resources.
+func newDecoder(encoding string, src io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "identity":
+		return ioutil.NopCloser(src), nil
+
+	case EncodingGzip, "x-gzip":
+		return gzip.NewReader(src)
+
+	case EncodingDeflate:
+		return zlib.NewReader(src)
+
+	case EncodingBrotli:
+		return ioutil.NopCloser(brotli.NewReader(src)), nil
+
+	case EncodingZstd:
+		zr, err := zstd.NewReader(src)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return zr.IOReadCloser(), nil
+	}
+
+	return nil, errUnsupportedEncoding
+}
@@ -0,0 +1,109 @@
+package thttp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zlib"
+	"github.com/valyala/fasthttp"
+)
+
+func TestDecompressBody(t *testing.T) {
+	body := []byte(strings.Repeat(`{"id":1,"name":"order"},`, 100))
+	c := newCompressor()
+
+	encode := func(encoding string, body []byte) []byte {
+		if encoding == EncodingDeflate {
+			var buf bytes.Buffer
+			w := zlib.NewWriter(&buf)
+			w.Write(body)
+			w.Close()
+
+			return buf.Bytes()
+		}
+
+		encoded, err := c.compress(encoding, body)
+
+		if err != nil {
+			t.Fatalf("could not encode body with %s - %s", encoding, err)
+		}
+
+		return encoded
+	}
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		maxSize         int
+		wantStatusCode  int
+		wantBody        []byte
+	}{
+		{name: "no content encoding", body: body, maxSize: len(body), wantBody: body},
+		{name: "identity", contentEncoding: "identity", body: body, maxSize: len(body), wantBody: body},
+		{name: "gzip", contentEncoding: "gzip", body: encode(EncodingGzip, body), maxSize: len(body), wantBody: body},
+		{name: "x-gzip", contentEncoding: "x-gzip", body: encode(EncodingGzip, body), maxSize: len(body), wantBody: body},
+		{name: "brotli", contentEncoding: "br", body: encode(EncodingBrotli, body), maxSize: len(body), wantBody: body},
+		{name: "zstd", contentEncoding: "zstd", body: encode(EncodingZstd, body), maxSize: len(body), wantBody: body},
+		{name: "deflate", contentEncoding: "deflate", body: encode(EncodingDeflate, body), maxSize: len(body), wantBody: body},
+		{
+			name:            "several encodings",
+			contentEncoding: "gzip, br",
+			body:            encode(EncodingBrotli, encode(EncodingGzip, body)),
+			maxSize:         len(body),
+			wantBody:        body,
+		},
+		{
+			name:            "unsupported encoding",
+			contentEncoding: "compress",
+			body:            body,
+			maxSize:         len(body),
+			wantStatusCode:  fasthttp.StatusUnsupportedMediaType,
+		},
+		{
+			name:            "decompressed body too large",
+			contentEncoding: "gzip",
+			body:            encode(EncodingGzip, body),
+			maxSize:         len(body) - 1,
+			wantStatusCode:  fasthttp.StatusRequestEntityTooLarge,
+		},
+		{
+			name:            "corrupted body",
+			contentEncoding: "gzip",
+			body:            []byte("not gzip"),
+			maxSize:         len(body),
+			wantStatusCode:  fasthttp.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("Content-Encoding", tt.contentEncoding)
+			httpCtx.Request.SetBody(tt.body)
+
+			statusCode, err := decompressBody(httpCtx, tt.maxSize)
+
+			if statusCode != tt.wantStatusCode {
+				t.Fatalf("decompressBody() statusCode = %v, want %v (err: %v)", statusCode, tt.wantStatusCode, err)
+			}
+
+			if tt.wantStatusCode != 0 {
+				if err == nil {
+					t.Error("decompressBody() must return an error")
+				}
+
+				return
+			}
+
+			if bytes.Equal(httpCtx.Request.Body(), tt.wantBody) == false {
+				t.Errorf("decompressBody() body = %s, want %s", httpCtx.Request.Body(), tt.wantBody)
+			}
+
+			if len(httpCtx.Request.Header.Peek("Content-Encoding")) != 0 {
+				t.Error("decompressBody() must remove the Content-Encoding header")
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package thttp
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// statusCoder is implemented by the errors which define the status code of the
+// response
+type statusCoder interface {
+	StatusCode() int
+}
+
+// Error is an error returned by a handler which is sent to the client with its
+// status code, eg: &thttp.Error{Status: 404, Message: "order not found"}
+type Error struct {
+	Status  int    `json:"-"`
+	Message string `json:"error"`
+}
+
+// Error return the message of the error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// StatusCode return the status code of the response
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+// FieldError describes a field of the request which is not valid
+type FieldError struct {
+	// Field is the path of the field (eg: "address.city")
+	Field string `json:"field"`
+	// Rule is the validation rule the field does not respect (eg: "required")
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when the request does not respect the validation
+// rules. It is sent with a 422 status code.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// Error return the messages of the fields
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+
+	for i, f := range e.Fields {
+		messages[i] = f.Field + " " + f.Message
+	}
+
+	return "Validation failed : " + strings.Join(messages, ", ")
+}
+
+// StatusCode return the status code of the response
+func (e *ValidationError) StatusCode() int {
+	return 422
+}
+
+// MarshalJSON encode the error with its fields
+func (e *ValidationError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}{"Validation failed", e.Fields})
+}
+
+// statusError return the error of the chain of err which defines the status
+// code of the response, nil if there is none
+func statusError(err error) statusCoder {
+	var sc statusCoder
+
+	if errors.As(err, &sc) == true && sc.StatusCode() > 0 {
+		return sc
+	}
+
+	return nil
+}
+
+// errorStatusCode return the status code of the error, 500 if it does not
+// define one
+func errorStatusCode(err error) int {
+	if sc := statusError(err); sc != nil {
+		return sc.StatusCode()
+	}
+
+	return 500
+}
@@ -0,0 +1,82 @@
+package thttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorStatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "plain error", err: errors.New("failure"), want: 500},
+		{name: "error with status", err: &Error{Status: 404, Message: "order not found"}, want: 404},
+		{name: "error without status", err: &Error{Message: "failure"}, want: 500},
+		{name: "validation error", err: &ValidationError{}, want: 422},
+		{name: "wrapped error", err: fmt.Errorf("binding : %w", &Error{Status: 400}), want: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStatusCode(tt.err); got != tt.want {
+				t.Errorf("errorStatusCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	notFound := &Error{Status: 404, Message: "order not found"}
+
+	tests := []struct {
+		name string
+		err  error
+		want statusCoder
+	}{
+		{name: "plain error", err: errors.New("failure"), want: nil},
+		{name: "error with status", err: notFound, want: notFound},
+		{name: "error without status", err: &Error{Message: "failure"}, want: nil},
+		{name: "wrapped error", err: fmt.Errorf("lookup : %w", notFound), want: notFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusError(tt.err); got != tt.want {
+				t.Errorf("statusError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrors_json(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "error",
+			err:  &Error{Status: 404, Message: "order not found"},
+			want: `{"error":"order not found"}`,
+		},
+		{
+			name: "validation error",
+			err:  &ValidationError{Fields: []FieldError{{Field: "name", Rule: "required", Message: "is required"}}},
+			want: `{"error":"Validation failed","fields":[{"field":"name","rule":"required","message":"is required"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.err)
+
+			if err != nil || string(got) != tt.want {
+				t.Errorf("json.Marshal() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,35 @@
 module github.com/nanux-io/thttp
 
 require (
-	github.com/klauspost/compress v1.9.1 // indirect
+	github.com/alicebob/miniredis/v2 v2.16.0
+	github.com/andybalholm/brotli v1.0.4
+	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/klauspost/compress v1.15.0
 	github.com/nanux-io/nanux v0.0.0-20191107140937-b47d3271034d
 	github.com/onsi/ginkgo v1.10.3
 	github.com/onsi/gomega v1.7.1
 	github.com/rs/zerolog v1.16.0
-	github.com/valyala/fasthttp v1.6.0
+	github.com/valyala/fasthttp v1.40.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	google.golang.org/protobuf v1.28.1
 	nanomsg.org/go-mangos v1.4.0
 	nanomsg.org/go/mangos/v2 v2.0.2
 )
 
-go 1.13
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/hpcloud/tail v1.0.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	github.com/yuin/gopher-lua v0.0.0-20200816102855-ee81675732da // indirect
+	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
+	golang.org/x/sys v0.0.0-20220227234510-4e6760a101f9 // indirect
+	golang.org/x/text v0.3.7 // indirect
+	gopkg.in/fsnotify.v1 v1.4.7 // indirect
+	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
+	gopkg.in/yaml.v2 v2.2.4 // indirect
+)
+
+go 1.18
@@ -0,0 +1,170 @@
+package thttp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// IPFilterConfig define the configuration of an IPFilter
+type IPFilterConfig struct {
+	// Allow are the networks allowed to access the routes. All the networks
+	// are allowed if there is none.
+	Allow []*net.IPNet
+	// Deny are the networks denied, even if they are allowed
+	Deny []*net.IPNet
+	// File contains networks added to the lists of the config, one per line
+	// prefixed by "allow" or "deny" (eg: "allow 10.8.0.0/16"). Empty lines and
+	// lines starting with "#" are ignored.
+	File string
+}
+
+// IPFilter allows or denies the requests according to the ip of the client,
+// resolved from the forwarding headers of the trusted proxies (see
+// WithTrustedProxies)
+type IPFilter struct {
+	cfg IPFilterConfig
+
+	mu    sync.RWMutex
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPFilter return a filter with the networks of the config and of its file
+func NewIPFilter(cfg IPFilterConfig) (*IPFilter, error) {
+	f := &IPFilter{cfg: cfg}
+
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// errIPFilterEmptyAllow is returned by Reload when the new allow list is empty
+// while the previous one was not
+var errIPFilterEmptyAllow = errors.New("IPFilter : the reload would allow all the networks")
+
+// Reload read the file of the config again (eg: on SIGHUP). The networks
+// previously loaded are kept if the file is invalid, or if the allow list
+// becomes empty since all the networks would then be allowed.
+func (f *IPFilter) Reload() error {
+	allow := append([]*net.IPNet{}, f.cfg.Allow...)
+	deny := append([]*net.IPNet{}, f.cfg.Deny...)
+
+	if f.cfg.File != "" {
+		fileAllow, fileDeny, err := readIPFilterFile(f.cfg.File)
+
+		if err != nil {
+			return err
+		}
+
+		allow = append(allow, fileAllow...)
+		deny = append(deny, fileDeny...)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(allow) == 0 && len(f.allow) > 0 {
+		return errIPFilterEmptyAllow
+	}
+
+	f.allow, f.deny = allow, deny
+
+	return nil
+}
+
+// Allowed tells if the ip is allowed
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if containsIP(f.deny, ip) == true {
+		return false
+	}
+
+	return len(f.allow) == 0 || containsIP(f.allow, ip) == true
+}
+
+// Middleware answer the requests of the clients which are not allowed with a
+// 403 status code. The same filter can be used by several routes.
+func (f *IPFilter) Middleware(fn nanux.HandlerFunc) nanux.HandlerFunc {
+	return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+		httpCtx, err := GetHTTPCtx(req)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Allowed(GetClient(req).IP) == false {
+			GetLogger(req).Debug().Msg("IPFilter : client not allowed")
+			httpCtx.SetStatusCode(fasthttp.StatusForbidden)
+
+			return nil, nil
+		}
+
+		return fn(ctx, req)
+	}
+}
+
+// containsIP tells if the ip is in one of the networks
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) == true {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readIPFilterFile return the allowed and denied networks of the file
+func readIPFilterFile(path string) (allow, deny []*net.IPNet, err error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") == true {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		if len(fields) != 2 {
+			return nil, nil, errors.New("IPFilter : malformed line " + line)
+		}
+
+		networks, err := ParseCIDRs(fields[1])
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch fields[0] {
+		case "allow":
+			allow = append(allow, networks...)
+		case "deny":
+			deny = append(deny, networks...)
+		default:
+			return nil, nil, errors.New("IPFilter : unknown rule " + fields[0])
+		}
+	}
+
+	return allow, deny, scanner.Err()
+}
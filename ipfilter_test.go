@@ -0,0 +1,178 @@
+package thttp
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestIPFilter_Allowed(t *testing.T) {
+	vpn, _ := ParseCIDRs("10.8.0.0/16")
+	banned, _ := ParseCIDRs("10.8.0.66", "203.0.113.0/24")
+
+	tests := []struct {
+		name string
+		cfg  IPFilterConfig
+		ip   string
+		want bool
+	}{
+		{name: "no list", cfg: IPFilterConfig{}, ip: "198.51.100.1", want: true},
+		{name: "allowed network", cfg: IPFilterConfig{Allow: vpn}, ip: "10.8.3.4", want: true},
+		{name: "not in the allowed networks", cfg: IPFilterConfig{Allow: vpn}, ip: "198.51.100.1", want: false},
+		{name: "denied network", cfg: IPFilterConfig{Deny: banned}, ip: "203.0.113.9", want: false},
+		{name: "not in the denied networks", cfg: IPFilterConfig{Deny: banned}, ip: "198.51.100.1", want: true},
+		{name: "denied in an allowed network", cfg: IPFilterConfig{Allow: vpn, Deny: banned}, ip: "10.8.0.66", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewIPFilter(tt.cfg)
+
+			if err != nil {
+				t.Fatalf("NewIPFilter() err = %v", err)
+			}
+
+			if got := f.Allowed(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("IPFilter.Allowed(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPFilter_Middleware(t *testing.T) {
+	vpn, _ := ParseCIDRs("10.8.0.0/16")
+	f, _ := NewIPFilter(IPFilterConfig{Allow: vpn})
+
+	tests := []struct {
+		name           string
+		client         Client
+		wantStatusCode int
+		wantCalled     bool
+	}{
+		{name: "allowed client", client: Client{IP: net.ParseIP("10.8.0.2")}, wantStatusCode: 200, wantCalled: true},
+		{name: "denied client", client: Client{IP: net.ParseIP("198.51.100.1")}, wantStatusCode: 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx, "client": tt.client}}
+			called := false
+
+			_, err := f.Middleware(func(*interface{}, nanux.Request) ([]byte, error) {
+				called = true
+
+				return nil, nil
+			})(nil, req)
+
+			if err != nil {
+				t.Fatalf("IPFilter.Middleware() - error occured when calling handler - %s", err)
+			}
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("IPFilter.Middleware() - status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			if called != tt.wantCalled {
+				t.Errorf("IPFilter.Middleware() - handler called = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestIPFilter_file(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thttp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	t.Run("load errors", func(t *testing.T) {
+		paths := []string{
+			filepath.Join(dir, "missing"),
+			writeFile("malformed", "allow\n"),
+			writeFile("unknown-rule", "block 10.0.0.0/8\n"),
+			writeFile("invalid-network", "allow 10.0.0.0/33\n"),
+		}
+
+		for _, path := range paths {
+			if _, err := NewIPFilter(IPFilterConfig{File: path}); err == nil {
+				t.Errorf("NewIPFilter(%s) must fail", path)
+			}
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		static, _ := ParseCIDRs("192.0.2.1")
+		path := writeFile("ipfilter", "# vpn\nallow 10.8.0.0/16\n\ndeny 10.8.0.66\n")
+		f, err := NewIPFilter(IPFilterConfig{Allow: static, File: path})
+
+		if err != nil {
+			t.Fatalf("NewIPFilter() err = %v", err)
+		}
+
+		for ip, want := range map[string]bool{"192.0.2.1": true, "10.8.1.1": true, "10.8.0.66": false, "10.9.0.1": false} {
+			if got := f.Allowed(net.ParseIP(ip)); got != want {
+				t.Errorf("IPFilter.Allowed(%s) = %v, want %v", ip, got, want)
+			}
+		}
+
+		writeFile("ipfilter", "allow 10.9.0.0/16\n")
+
+		if err := f.Reload(); err != nil {
+			t.Fatalf("IPFilter.Reload() err = %v", err)
+		}
+
+		for ip, want := range map[string]bool{"192.0.2.1": true, "10.8.1.1": false, "10.9.0.1": true} {
+			if got := f.Allowed(net.ParseIP(ip)); got != want {
+				t.Errorf("IPFilter.Allowed(%s) after reload = %v, want %v", ip, got, want)
+			}
+		}
+
+		writeFile("ipfilter", "allow\n")
+
+		if err := f.Reload(); err == nil {
+			t.Errorf("IPFilter.Reload() must fail with an invalid file")
+		}
+
+		if f.Allowed(net.ParseIP("10.9.0.1")) == false {
+			t.Errorf("IPFilter.Reload() must keep the networks when the file is invalid")
+		}
+	})
+
+	t.Run("reload emptying the allow list", func(t *testing.T) {
+		path := writeFile("ipfilter-empty", "allow 10.8.0.0/16\n")
+		f, err := NewIPFilter(IPFilterConfig{File: path})
+
+		if err != nil {
+			t.Fatalf("NewIPFilter() err = %v", err)
+		}
+
+		writeFile("ipfilter-empty", "# truncated\ndeny 10.8.0.66\n")
+
+		if err := f.Reload(); err != errIPFilterEmptyAllow {
+			t.Errorf("IPFilter.Reload() err = %v, want %v", err, errIPFilterEmptyAllow)
+		}
+
+		if f.Allowed(net.ParseIP("203.0.113.7")) == true || f.Allowed(net.ParseIP("10.8.0.66")) == false {
+			t.Errorf("IPFilter.Reload() must keep the networks when the allow list becomes empty")
+		}
+	})
+}
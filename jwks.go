@@ -0,0 +1,325 @@
+package thttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJWKSTTL is the duration for which the keys of a JWKS are cached when
+// no ttl is specified
+const DefaultJWKSTTL = 10 * time.Minute
+
+// jwksMinRefreshInterval is the minimum duration between two fetches of a JWKS
+// document triggered by an unknown key id. It is also the delay before the
+// first retry after a failed fetch, which doubles with each new failure.
+const jwksMinRefreshInterval = 10 * time.Second
+
+// JWKS is a KeySet whose keys come from a JSON Web Key Set document (RFC 7517)
+// read from a file or fetched from an URL. The keys are cached and the
+// document is read again when the cache expires or when a token is signed by
+// an unknown key.
+type JWKS struct {
+	source string
+	ttl    time.Duration
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	// refreshing is closed once the fetch in progress is done, it is nil when
+	// there is none
+	refreshing chan struct{}
+	// err is the error of the last fetch, failures the number of fetches which
+	// have failed in a row and retryAt the time before which the document is not
+	// fetched again after a failure
+	err      error
+	failures int
+	retryAt  time.Time
+}
+
+// NewJWKS return a JWKS whose document is read from source, which is an http(s)
+// URL or a file path. The keys are cached for the ttl duration, or for
+// DefaultJWKSTTL if it is not positive.
+func NewJWKS(source string, ttl time.Duration) *JWKS {
+	if ttl <= 0 {
+		ttl = DefaultJWKSTTL
+	}
+
+	return &JWKS{
+		source: source,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Key return the key with the specified id. When the document contains a
+// single key, it is also used for the tokens without kid. The document is
+// fetched by a single goroutine: the cached keys keep being used meanwhile and
+// only the requests whose key is not cached wait for it. The cached keys are
+// kept when the document can not be read.
+func (j *JWKS) Key(kid string) (interface{}, error) {
+	j.mu.Lock()
+
+	key, ok := j.keys[kid]
+
+	if j.mustRefresh(ok, time.Now()) == true {
+		done := j.refresh()
+
+		if ok == false {
+			j.mu.Unlock()
+			<-done
+			j.mu.Lock()
+
+			key, ok = j.keys[kid]
+		}
+	}
+
+	keys, err := j.keys, j.err
+	j.mu.Unlock()
+
+	if ok == true {
+		return key, nil
+	}
+
+	if keys == nil && err != nil {
+		return nil, err
+	}
+
+	return nil, errJWTKeyNotFound
+}
+
+// mustRefresh tells if the document must be fetched because a fetch is in
+// progress, the cache has expired or the key is unknown. It is not fetched
+// again before the end of the backoff following a failure. j.mu must be held.
+func (j *JWKS) mustRefresh(known bool, now time.Time) bool {
+	if j.refreshing != nil {
+		return true
+	}
+
+	if now.Before(j.retryAt) == true {
+		return false
+	}
+
+	expired := now.Sub(j.fetchedAt) >= j.ttl
+	unknown := known == false && now.Sub(j.fetchedAt) >= jwksMinRefreshInterval
+
+	return expired == true || unknown == true
+}
+
+// refresh start fetching the document in its own goroutine, unless a fetch is
+// already in progress, and return a channel closed once it is done. j.mu must
+// be held.
+func (j *JWKS) refresh() chan struct{} {
+	if j.refreshing != nil {
+		return j.refreshing
+	}
+
+	done := make(chan struct{})
+	j.refreshing = done
+
+	go func() {
+		keys, err := j.fetch()
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		now := time.Now()
+		j.err = err
+
+		if err == nil {
+			j.keys = keys
+			j.fetchedAt = now
+			j.failures = 0
+		} else {
+			shift := j.failures
+
+			if shift > 5 {
+				shift = 5
+			}
+
+			j.failures++
+			j.retryAt = now.Add(jwksMinRefreshInterval << uint(shift))
+		}
+
+		j.refreshing = nil
+		close(done)
+	}()
+
+	return done
+}
+
+// fetch read the document and parse its keys
+func (j *JWKS) fetch() (map[string]interface{}, error) {
+	var data []byte
+	var err error
+
+	if strings.HasPrefix(j.source, "http://") == true || strings.HasPrefix(j.source, "https://") == true {
+		data, err = j.fetchURL()
+	} else {
+		data, err = ioutil.ReadFile(j.source)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJWKS(data)
+}
+
+func (j *JWKS) fetchURL() ([]byte, error) {
+	resp, err := j.client.Get(j.source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS : unexpected status code %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// jwk is a JSON Web Key. Only the members of the supported key types are
+// decoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS parse the signature keys of a JWKS document. The keys of an
+// unsupported type are ignored.
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	if len(doc.Keys) == 1 {
+		for _, key := range keys {
+			keys[""] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey return the key verifying the signatures. nil is returned for
+// unsupported key types.
+func (k jwk) publicKey() (interface{}, error) {
+	decode := func(member, value string) ([]byte, error) {
+		decoded, err := base64.RawURLEncoding.DecodeString(value)
+
+		if err != nil || len(decoded) == 0 {
+			return nil, fmt.Errorf("JWKS : invalid member %s of key %q", member, k.Kid)
+		}
+
+		return decoded, nil
+	}
+
+	switch k.Kty {
+	case "oct":
+		return decode("k", k.K)
+
+	case "RSA":
+		n, err := decode("n", k.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decode("e", k.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+
+		x, err := decode("x", k.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decode("y", k.Y)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+
+		if pub.Curve.IsOnCurve(pub.X, pub.Y) == false {
+			return nil, errors.New("JWKS : point of key " + k.Kid + " is not on the curve")
+		}
+
+		return pub, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+
+		x, err := decode("x", k.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("JWKS : invalid size of key " + k.Kid)
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, nil
+}
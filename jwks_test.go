@@ -0,0 +1,175 @@
+package thttp
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksDocument build the JWKS document of the public keys
+func jwksDocument(keys testKeys) []byte {
+	b64 := base64.RawURLEncoding.EncodeToString
+	pad := func(n *big.Int) string {
+		return b64(leftPad(n.Bytes(), 32))
+	}
+
+	doc, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "oct", "kid": "hmac", "k": b64(keys.hmac)},
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(keys.rsa.N.Bytes()), "e": b64(big.NewInt(int64(keys.rsa.E)).Bytes())},
+			{"kty": "EC", "kid": "ecdsa", "crv": "P-256", "x": pad(keys.ecdsa.X), "y": pad(keys.ecdsa.Y)},
+			{"kty": "OKP", "kid": "ed25519", "crv": "Ed25519", "x": b64(keys.ed25519.Public().(ed25519.PublicKey))},
+			{"kty": "RSA", "kid": "encryption", "use": "enc", "n": "AQAB", "e": "AQAB"},
+			{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AQAB", "y": "AQAB"},
+		},
+	})
+
+	return doc
+}
+
+func TestJWKS(t *testing.T) {
+	keys := newTestKeys(t)
+	doc := jwksDocument(keys)
+	var fetches int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(doc)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "thttp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "jwks.json")
+
+	if err := ioutil.WriteFile(path, doc, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range []string{server.URL, path} {
+		t.Run(source, func(t *testing.T) {
+			jwks := NewJWKS(source, time.Hour)
+			cfg := JWTConfig{Keys: jwks, Algorithms: []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA}}
+			claims := map[string]interface{}{"sub": "alice"}
+
+			for _, key := range []struct{ alg, kid string }{
+				{AlgHS256, "hmac"},
+				{AlgRS256, "rsa"},
+				{AlgES256, "ecdsa"},
+				{AlgEdDSA, "ed25519"},
+			} {
+				if _, err := verifyJWT(signJWT(t, keys, key.alg, key.kid, claims), cfg, time.Now()); err != nil {
+					t.Errorf("JWKS - token signed with %s could not be verified - %s", key.kid, err)
+				}
+			}
+
+			for _, kid := range []string{"encryption", "p384", "unknown"} {
+				if _, err := jwks.Key(kid); err != errJWTKeyNotFound {
+					t.Errorf("JWKS.Key(%s) err = %v, want %v", kid, err, errJWTKeyNotFound)
+				}
+			}
+		})
+	}
+
+	if fetches != 1 {
+		t.Errorf("JWKS - document fetched %d times, want 1", fetches)
+	}
+}
+
+func TestJWKS_errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invalid":
+			w.Write([]byte(`{"keys": [{"kty": "RSA", "kid": "rsa", "n": "!!!", "e": "AQAB"}]}`))
+		case "/malformed":
+			w.Write([]byte(`not json`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for _, source := range []string{server.URL + "/invalid", server.URL + "/malformed", server.URL + "/missing", "/missing/jwks.json"} {
+		t.Run(source, func(t *testing.T) {
+			if _, err := NewJWKS(source, time.Hour).Key("rsa"); err == nil {
+				t.Errorf("JWKS.Key() must fail for %s", source)
+			}
+		})
+	}
+}
+
+func TestJWKS_refresh(t *testing.T) {
+	keys := newTestKeys(t)
+	doc := jwksDocument(keys)
+	var fetches int32
+	var failing int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write(doc)
+	}))
+	defer server.Close()
+
+	if jwks := NewJWKS(server.URL, 0); jwks.ttl != DefaultJWKSTTL {
+		t.Errorf("NewJWKS() ttl = %v, want %v", jwks.ttl, DefaultJWKSTTL)
+	}
+
+	jwks := NewJWKS(server.URL, time.Millisecond)
+
+	if _, err := jwks.Key("rsa"); err != nil {
+		t.Fatalf("JWKS.Key() err = %v", err)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	time.Sleep(2 * time.Millisecond)
+
+	// the cached keys are used while the document is fetched again and after
+	// the fetch has failed
+	for i := 0; i < 10; i++ {
+		if _, err := jwks.Key("rsa"); err != nil {
+			t.Fatalf("JWKS.Key() must use the cached keys during an outage, err = %v", err)
+		}
+
+		jwks.mu.Lock()
+		done := jwks.refreshing
+		jwks.mu.Unlock()
+
+		if done != nil {
+			<-done
+		}
+	}
+
+	if fetches != 2 {
+		t.Errorf("JWKS - document fetched %d times, want 2", fetches)
+	}
+
+	if jwks.failures != 1 || jwks.retryAt.Sub(time.Now()) <= jwksMinRefreshInterval/2 {
+		t.Errorf("JWKS - a failed fetch must delay the next one, failures = %d, retry in %v", jwks.failures, jwks.retryAt.Sub(time.Now()))
+	}
+
+	// the unknown keys do not trigger a fetch during the backoff
+	if _, err := jwks.Key("unknown"); err != errJWTKeyNotFound || fetches != 2 {
+		t.Errorf("JWKS.Key(unknown) err = %v, fetches = %d", err, fetches)
+	}
+}
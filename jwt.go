@@ -0,0 +1,371 @@
+package thttp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/nanux-io/nanux"
+)
+
+// JWT signing algorithms supported by the JWTAuth middleware
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	errJWTMalformed       = errors.New("JWT : malformed token")
+	errJWTAlgorithm       = errors.New("JWT : algorithm not allowed")
+	errJWTSignature       = errors.New("JWT : invalid signature")
+	errJWTExpired         = errors.New("JWT : token expired")
+	errJWTNotValidYet     = errors.New("JWT : token not valid yet")
+	errJWTInvalidIssuer   = errors.New("JWT : invalid issuer")
+	errJWTInvalidAudience = errors.New("JWT : invalid audience")
+	errJWTKeyNotFound     = errors.New("JWT : key not found")
+	errJWTKeyType         = errors.New("JWT : key type does not match the algorithm")
+)
+
+// KeySet provides the keys verifying the signatures of the tokens
+type KeySet interface {
+	// Key return the key with the specified id. The id is empty when the token
+	// has no kid header.
+	Key(kid string) (interface{}, error)
+}
+
+// StaticKeys is a KeySet whose keys are indexed by their id. The key with an
+// empty id is used for the tokens without kid. Keys are []byte for HS256,
+// *rsa.PublicKey for RS256, *ecdsa.PublicKey for ES256 and ed25519.PublicKey
+// for EdDSA.
+type StaticKeys map[string]interface{}
+
+// Key return the key with the specified id
+func (k StaticKeys) Key(kid string) (interface{}, error) {
+	key, ok := k[kid]
+
+	if ok == false {
+		return nil, errJWTKeyNotFound
+	}
+
+	return key, nil
+}
+
+// JWTConfig define the configuration of the JWTAuth middleware
+type JWTConfig struct {
+	// Keys verifying the signatures (eg: StaticKeys or a JWKS)
+	Keys KeySet
+	// Algorithms allowed. Default to all the supported ones.
+	Algorithms []string
+	// Issuer is the expected iss claim. It is not checked if empty.
+	Issuer string
+	// Audience is the expected aud claim. It is not checked if empty.
+	Audience string
+	// Leeway is the clock skew tolerated on the exp and nbf claims
+	Leeway time.Duration
+	// Cookie is the name of the cookie containing the token when there is no
+	// Authorization header. The cookie is not read if empty.
+	Cookie string
+	// Realm is sent in the WWW-Authenticate header. Default to "Restricted".
+	Realm string
+}
+
+// JWTAuth return a middleware authenticating the requests with a JWT sent as a
+// bearer token in the Authorization header or in a cookie. The claims of valid
+// tokens are injected in `req.M["jwtClaims"]` and the principal in
+// `req.M["principal"]`: its ID is the sub claim, its scopes come from the scope
+// or scp claims and its roles from the roles claim. Requests without a valid
+// token are answered with a 401 status code. It panics if cfg.Keys is nil.
+func JWTAuth(cfg JWTConfig) nanux.Middleware {
+	if cfg.Keys == nil {
+		panic("JWTAuth : cfg.Keys must be set")
+	}
+
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA}
+	}
+
+	if cfg.Realm == "" {
+		cfg.Realm = "Restricted"
+	}
+
+	challenge := `Bearer realm="` + cfg.Realm + `"`
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			token, ok := parseBearerAuth(httpCtx)
+
+			if ok == false && cfg.Cookie != "" {
+				token = string(httpCtx.Request.Header.Cookie(cfg.Cookie))
+				ok = token != ""
+			}
+
+			if ok == false {
+				unauthorized(httpCtx, challenge)
+
+				return nil, nil
+			}
+
+			claims, err := verifyJWT(token, cfg, time.Now())
+
+			if err != nil {
+				GetLogger(req).Debug().Err(err).Msg("JWTAuth : invalid token")
+				unauthorized(httpCtx, challenge+`, error="invalid_token"`)
+
+				return nil, nil
+			}
+
+			req.M["jwtClaims"] = claims
+			req.M["principal"] = claimsPrincipal(claims)
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// GetJWTClaims return the claims of the token injected in the nanux request by
+// the JWTAuth middleware. nil is returned if there is none.
+func GetJWTClaims(req nanux.Request) map[string]interface{} {
+	claims, _ := req.M["jwtClaims"].(map[string]interface{})
+
+	return claims
+}
+
+// claimsPrincipal build the principal from the claims of a token
+func claimsPrincipal(claims map[string]interface{}) Principal {
+	principal := Principal{Claims: claims}
+	principal.ID, _ = claims["sub"].(string)
+
+	if scope, ok := claims["scope"].(string); ok == true {
+		principal.Scopes = strings.Fields(scope)
+	} else {
+		principal.Scopes = stringsClaim(claims["scp"])
+	}
+
+	principal.Roles = stringsClaim(claims["roles"])
+
+	return principal
+}
+
+// stringsClaim return the strings of a claim which is an array of strings
+func stringsClaim(claim interface{}) []string {
+	values, _ := claim.([]interface{})
+	strs := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if str, ok := value.(string); ok == true {
+			strs = append(strs, str)
+		}
+	}
+
+	return strs
+}
+
+// verifyJWT check the signature and the claims of a token in compact
+// serialization and return its claims
+func verifyJWT(token string, cfg JWTConfig, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, errJWTMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	if containsString(cfg.Algorithms, header.Alg) == false {
+		return nil, errJWTAlgorithm
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, errJWTMalformed
+	}
+
+	key, err := cfg.Keys.Key(header.Kid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if err := verifyJWTClaims(claims, cfg, now); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// decodeJWTPart decode a base64url encoded JSON part of a token
+func decodeJWTPart(part string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+
+	if err != nil {
+		return errJWTMalformed
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(decoded)).Decode(v); err != nil {
+		return errJWTMalformed
+	}
+
+	return nil
+}
+
+// verifyJWTSignature check the signature of the signing input with the key.
+// The type of the key must match the algorithm.
+func verifyJWTSignature(alg string, key interface{}, signingInput, signature []byte) error {
+	hash := sha256.Sum256(signingInput)
+	valid := false
+
+	switch alg {
+	case AlgHS256:
+		secret, ok := key.([]byte)
+
+		if ok == false {
+			return errJWTKeyType
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		valid = hmac.Equal(mac.Sum(nil), signature)
+
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+
+		if ok == false {
+			return errJWTKeyType
+		}
+
+		valid = rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signature) == nil
+
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+
+		if ok == false || pub.Curve != elliptic.P256() {
+			return errJWTKeyType
+		}
+
+		if len(signature) != 64 {
+			return errJWTSignature
+		}
+
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		valid = ecdsa.Verify(pub, hash[:], r, s)
+
+	case AlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+
+		if ok == false {
+			return errJWTKeyType
+		}
+
+		valid = ed25519.Verify(pub, signingInput, signature)
+
+	default:
+		return errJWTAlgorithm
+	}
+
+	if valid == false {
+		return errJWTSignature
+	}
+
+	return nil
+}
+
+// verifyJWTClaims check the time validity, the issuer and the audience of a
+// token
+func verifyJWTClaims(claims map[string]interface{}, cfg JWTConfig, now time.Time) error {
+	leeway := cfg.Leeway.Seconds()
+	unixNow := float64(now.UnixNano()) / float64(time.Second)
+
+	if expI, exists := claims["exp"]; exists == true {
+		exp, ok := expI.(float64)
+
+		if ok == false {
+			return errJWTMalformed
+		}
+
+		if unixNow > exp+leeway {
+			return errJWTExpired
+		}
+	}
+
+	if nbfI, exists := claims["nbf"]; exists == true {
+		nbf, ok := nbfI.(float64)
+
+		if ok == false {
+			return errJWTMalformed
+		}
+
+		if unixNow < nbf-leeway {
+			return errJWTNotValidYet
+		}
+	}
+
+	if cfg.Issuer != "" && claims["iss"] != cfg.Issuer {
+		return errJWTInvalidIssuer
+	}
+
+	if cfg.Audience != "" {
+		switch aud := claims["aud"].(type) {
+		case string:
+			if aud != cfg.Audience {
+				return errJWTInvalidAudience
+			}
+		case []interface{}:
+			if containsString(stringsClaim(aud), cfg.Audience) == false {
+				return errJWTInvalidAudience
+			}
+		default:
+			return errJWTInvalidAudience
+		}
+	}
+
+	return nil
+}
+
+// containsString tells if the value is in the slice
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,282 @@
+package thttp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// testKeys are the private keys signing the tokens of the tests
+type testKeys struct {
+	hmac    []byte
+	rsa     *rsa.PrivateKey
+	ecdsa   *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKeys{hmac: []byte("hmac secret"), rsa: rsaKey, ecdsa: ecdsaKey, ed25519: ed25519Key}
+}
+
+// signJWT create a token signed with the key of the algorithm
+func signJWT(t *testing.T, keys testKeys, alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	var err error
+
+	switch alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, keys.hmac)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case AlgRS256:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, keys.rsa, crypto.SHA256, hash[:])
+	case AlgES256:
+		r, s, signErr := ecdsa.Sign(rand.Reader, keys.ecdsa, hash[:])
+		signature = append(leftPad(r.Bytes(), 32), leftPad(s.Bytes(), 32)...)
+		err = signErr
+	case AlgEdDSA:
+		signature = ed25519.Sign(keys.ed25519, []byte(signingInput))
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// leftPad pad the big endian bytes of a number with zeros up to size
+func leftPad(b []byte, size int) []byte {
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func TestVerifyJWT(t *testing.T) {
+	keys := newTestKeys(t)
+	now := time.Now()
+
+	cfg := JWTConfig{
+		Keys: StaticKeys{
+			"hmac":    keys.hmac,
+			"rsa":     &keys.rsa.PublicKey,
+			"ecdsa":   &keys.ecdsa.PublicKey,
+			"ed25519": keys.ed25519.Public(),
+		},
+		Algorithms: []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA},
+		Issuer:     "https://auth.example.com",
+		Audience:   "orders",
+		Leeway:     time.Minute,
+	}
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub": "alice",
+			"iss": "https://auth.example.com",
+			"aud": "orders",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	}
+
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		claims[name] = value
+
+		return claims
+	}
+
+	// the payload of a valid token is replaced by another one
+	validParts := strings.Split(signJWT(t, keys, AlgHS256, "hmac", validClaims()), ".")
+	otherParts := strings.Split(signJWT(t, keys, AlgHS256, "hmac", withClaim("sub", "bob")), ".")
+	tamperedToken := validParts[0] + "." + otherParts[1] + "." + validParts[2]
+
+	tests := []struct {
+		name    string
+		token   string
+		cfg     JWTConfig
+		wantErr error
+	}{
+		{name: "HS256", token: signJWT(t, keys, AlgHS256, "hmac", validClaims())},
+		{name: "RS256", token: signJWT(t, keys, AlgRS256, "rsa", validClaims())},
+		{name: "ES256", token: signJWT(t, keys, AlgES256, "ecdsa", validClaims())},
+		{name: "EdDSA", token: signJWT(t, keys, AlgEdDSA, "ed25519", validClaims())},
+		{name: "audience in an array", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("aud", []string{"billing", "orders"}))},
+		{name: "expired within the leeway", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("exp", now.Add(-30*time.Second).Unix()))},
+		{name: "expired", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("exp", now.Add(-2*time.Minute).Unix())), wantErr: errJWTExpired},
+		{name: "not valid yet", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("nbf", now.Add(2*time.Minute).Unix())), wantErr: errJWTNotValidYet},
+		{name: "expiration not a number", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("exp", "tomorrow")), wantErr: errJWTMalformed},
+		{name: "not before not a number", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("nbf", nil)), wantErr: errJWTMalformed},
+		{name: "invalid issuer", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("iss", "https://evil.example.com")), wantErr: errJWTInvalidIssuer},
+		{name: "invalid audience", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("aud", "billing")), wantErr: errJWTInvalidAudience},
+		{name: "missing audience", token: signJWT(t, keys, AlgHS256, "hmac", withClaim("aud", nil)), wantErr: errJWTInvalidAudience},
+		{name: "unknown key", token: signJWT(t, keys, AlgHS256, "other", validClaims()), wantErr: errJWTKeyNotFound},
+		{name: "key of another type", token: signJWT(t, keys, AlgHS256, "rsa", validClaims()), wantErr: errJWTKeyType},
+		{name: "algorithm none", token: signJWT(t, keys, "none", "hmac", validClaims()), wantErr: errJWTAlgorithm},
+		{
+			name:    "algorithm not allowed",
+			token:   signJWT(t, keys, AlgHS256, "hmac", validClaims()),
+			cfg:     JWTConfig{Keys: cfg.Keys, Algorithms: []string{AlgRS256}},
+			wantErr: errJWTAlgorithm,
+		},
+		{
+			name:    "invalid signature",
+			token:   tamperedToken,
+			wantErr: errJWTSignature,
+		},
+		{name: "malformed token", token: "not.a.jwt", wantErr: errJWTMalformed},
+		{name: "token without signature", token: "header.payload", wantErr: errJWTMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifyCfg := cfg
+
+			if tt.cfg.Keys != nil {
+				verifyCfg = tt.cfg
+			}
+
+			claims, err := verifyJWT(tt.token, verifyCfg, now)
+
+			if err != tt.wantErr {
+				t.Fatalf("verifyJWT() err = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && claims["sub"] != "alice" {
+				t.Errorf("verifyJWT() sub = %v, want alice", claims["sub"])
+			}
+		})
+	}
+}
+
+func TestClaimsPrincipal(t *testing.T) {
+	tests := []struct {
+		name          string
+		claims        map[string]interface{}
+		wantPrincipal Principal
+	}{
+		{
+			name:          "scope claim",
+			claims:        map[string]interface{}{"sub": "alice", "scope": "orders:read orders:write", "roles": []interface{}{"admin"}},
+			wantPrincipal: Principal{ID: "alice", Scopes: []string{"orders:read", "orders:write"}, Roles: []string{"admin"}},
+		},
+		{
+			name:          "scp claim",
+			claims:        map[string]interface{}{"sub": "alice", "scp": []interface{}{"orders:read"}},
+			wantPrincipal: Principal{ID: "alice", Scopes: []string{"orders:read"}, Roles: []string{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.wantPrincipal.Claims = tt.claims
+
+			if got := claimsPrincipal(tt.claims); !reflect.DeepEqual(got, tt.wantPrincipal) {
+				t.Errorf("claimsPrincipal() = %+v, want %+v", got, tt.wantPrincipal)
+			}
+		})
+	}
+}
+
+func TestJWTAuth(t *testing.T) {
+	keys := newTestKeys(t)
+	token := signJWT(t, keys, AlgHS256, "", map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+
+	cfg := JWTConfig{Keys: StaticKeys{"": keys.hmac}, Cookie: "session"}
+
+	tests := []struct {
+		name           string
+		authorization  string
+		cookie         string
+		wantStatusCode int
+		wantSubject    string
+	}{
+		{name: "token in the authorization header", authorization: "Bearer " + token, wantStatusCode: 200, wantSubject: "alice"},
+		{name: "token in the cookie", cookie: token, wantStatusCode: 200, wantSubject: "alice"},
+		{name: "invalid token", authorization: "Bearer " + token + "x", wantStatusCode: 401},
+		{name: "missing token", wantStatusCode: 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("Authorization", tt.authorization)
+
+			if tt.cookie != "" {
+				httpCtx.Request.Header.SetCookie("session", tt.cookie)
+			}
+
+			req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+
+			var gotSubject interface{}
+			var gotPrincipal Principal
+
+			_, err := JWTAuth(cfg)(func(_ *interface{}, req nanux.Request) ([]byte, error) {
+				gotSubject = GetJWTClaims(req)["sub"]
+				gotPrincipal, _ = GetPrincipal(req)
+
+				return nil, nil
+			})(nil, req)
+
+			if err != nil {
+				t.Fatalf("JWTAuth() - error occured when calling handler - %s", err)
+			}
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("JWTAuth() - status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			if tt.wantSubject != "" && (gotSubject != tt.wantSubject || gotPrincipal.ID != tt.wantSubject) {
+				t.Errorf("JWTAuth() - subject = %v, principal = %v, want %v", gotSubject, gotPrincipal.ID, tt.wantSubject)
+			}
+
+			if tt.wantStatusCode == 401 && len(httpCtx.Response.Header.Peek("WWW-Authenticate")) == 0 {
+				t.Error("JWTAuth() - WWW-Authenticate header must be set")
+			}
+		})
+	}
+}
+
+func TestJWTAuth_missingKeys(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("JWTAuth() must panic when the keys are not set")
+		}
+	}()
+
+	JWTAuth(JWTConfig{})
+}
@@ -1,9 +1,19 @@
 package thttp
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+
 	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
 )
 
+// SecurityHeadersOpt define the handler option key for overriding the headers
+// set by the SecurityHeaders middleware on a route. The value must be a
+// map[string]string, an empty value removes the header.
+const SecurityHeadersOpt nanux.HandlerOptName = "httpSecurityHeaders"
+
 // OKOptions respond to the request with an empty body and status code 200
 // to options request. Because several libs (in different language) make options
 // request before doing the "real" request, this middleware is here to help
@@ -38,3 +48,93 @@ func SetApplicationJSON(fn nanux.HandlerFunc) nanux.HandlerFunc {
 		return fn(ctx, req)
 	}
 }
+
+// DefaultSecurityHeaders return the headers set by SecurityHeaders when no
+// headers are provided
+func DefaultSecurityHeaders() map[string]string {
+	return map[string]string{
+		"Strict-Transport-Security": "max-age=63072000; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Permissions-Policy":        "camera=(), microphone=(), geolocation=()",
+		"Content-Security-Policy":   "default-src 'self'; frame-ancestors 'none'",
+	}
+}
+
+// SecurityHeaders return a middleware setting security headers on the
+// responses, DefaultSecurityHeaders if headers is nil. The "{nonce}"
+// placeholder of a header (eg: "script-src 'nonce-{nonce}'") is replaced by a
+// nonce generated for each request and injected in `req.M["cspNonce"]`. A
+// route can override the headers with the SecurityHeadersOpt handler option.
+func SecurityHeaders(headers map[string]string) nanux.Middleware {
+	if headers == nil {
+		headers = DefaultSecurityHeaders()
+	}
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			overrides, _ := GetHandlerOpts(req)[SecurityHeadersOpt].(map[string]string)
+
+			for name, value := range headers {
+				if _, ok := overrides[name]; ok == false {
+					if err := setSecurityHeader(httpCtx, req, name, value); err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			for name, value := range overrides {
+				if err := setSecurityHeader(httpCtx, req, name, value); err != nil {
+					return nil, err
+				}
+			}
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// GetCSPNonce return the nonce generated for the request by the
+// SecurityHeaders middleware, empty if there is none
+func GetCSPNonce(req nanux.Request) string {
+	nonce, _ := req.M["cspNonce"].(string)
+
+	return nonce
+}
+
+// setSecurityHeader set the header of the response after replacing its nonce
+// placeholder. The header is not set if the value is empty.
+func setSecurityHeader(httpCtx *fasthttp.RequestCtx, req nanux.Request, name, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if strings.Contains(value, "{nonce}") == true {
+		nonce := GetCSPNonce(req)
+
+		// the nonce is shared by all the headers of the request
+		if nonce == "" {
+			b := make([]byte, 16)
+
+			if _, err := rand.Read(b); err != nil {
+				return err
+			}
+
+			nonce = base64.StdEncoding.EncodeToString(b)
+			req.M["cspNonce"] = nonce
+		}
+
+		value = strings.Replace(value, "{nonce}", nonce, -1)
+	}
+
+	httpCtx.Response.Header.Set(name, value)
+
+	return nil
+}
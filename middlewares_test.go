@@ -1,6 +1,7 @@
 package thttp
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/nanux-io/nanux"
@@ -90,3 +91,104 @@ func TestOKOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		opts        nanux.HandlerOpts
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "default headers",
+			wantHeaders: DefaultSecurityHeaders(),
+		},
+		{
+			name:    "custom headers",
+			headers: map[string]string{"X-Frame-Options": "SAMEORIGIN"},
+			wantHeaders: map[string]string{
+				"X-Frame-Options":        "SAMEORIGIN",
+				"X-Content-Type-Options": "",
+			},
+		},
+		{
+			name: "route override",
+			opts: nanux.HandlerOpts{SecurityHeadersOpt: map[string]string{
+				"X-Frame-Options":         "",
+				"Content-Security-Policy": "default-src 'self' https://cdn.example.com",
+			}},
+			wantHeaders: map[string]string{
+				"X-Frame-Options":           "",
+				"Content-Security-Policy":   "default-src 'self' https://cdn.example.com",
+				"Strict-Transport-Security": "max-age=63072000; includeSubDomains",
+			},
+		},
+		{
+			// the type of the option is checked when the handler is added
+			name: "route override of wrong type is ignored",
+			opts: nanux.HandlerOpts{SecurityHeadersOpt: "X-Frame-Options: SAMEORIGIN"},
+			wantHeaders: map[string]string{
+				"X-Frame-Options": "DENY",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx, "handlerOpts": tt.opts}}
+
+			_, err := SecurityHeaders(tt.headers)(func(*interface{}, nanux.Request) ([]byte, error) {
+				return nil, nil
+			})(nil, req)
+
+			if err != nil {
+				t.Fatalf("SecurityHeaders() - error occured when calling handler - %s", err)
+			}
+
+			for name, want := range tt.wantHeaders {
+				if got := string(httpCtx.Response.Header.Peek(name)); got != want {
+					t.Errorf("SecurityHeaders() - %s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders_nonce(t *testing.T) {
+	headers := map[string]string{
+		"Content-Security-Policy":             "script-src 'nonce-{nonce}'",
+		"Content-Security-Policy-Report-Only": "style-src 'nonce-{nonce}'",
+	}
+
+	var nonces []string
+
+	for i := 0; i < 2; i++ {
+		httpCtx := &fasthttp.RequestCtx{}
+		req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+
+		SecurityHeaders(headers)(func(_ *interface{}, req nanux.Request) ([]byte, error) {
+			nonces = append(nonces, GetCSPNonce(req))
+
+			return nil, nil
+		})(nil, req)
+
+		nonce := nonces[i]
+
+		if nonce == "" || strings.Contains(nonce, "{") == true {
+			t.Fatalf("GetCSPNonce() = %s", nonce)
+		}
+
+		if got := string(httpCtx.Response.Header.Peek("Content-Security-Policy")); got != "script-src 'nonce-"+nonce+"'" {
+			t.Errorf("SecurityHeaders() - Content-Security-Policy = %s", got)
+		}
+
+		if got := string(httpCtx.Response.Header.Peek("Content-Security-Policy-Report-Only")); got != "style-src 'nonce-"+nonce+"'" {
+			t.Errorf("SecurityHeaders() - Content-Security-Policy-Report-Only = %s", got)
+		}
+	}
+
+	if nonces[0] == nonces[1] {
+		t.Errorf("SecurityHeaders() - nonce must be different for each request")
+	}
+}
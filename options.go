@@ -10,6 +10,31 @@ const (
 	// to the handler. The value associated to this option come from the http
 	// package of the std lib (eg http.MethodGet)
 	MethodsOpt nanux.HandlerOptName = "httpMethod"
+
+	// MaxBodyOpt define the handler option key for specifying the maximum size
+	// in bytes of the request body. The value must be an int. Requests with a
+	// bigger body are answered with a 413 status code. The limit can not exceed
+	// the MaxRequestBodySize of the fasthttp server, unless the route streams
+	// its body (see StreamBodyOpt).
+	MaxBodyOpt nanux.HandlerOptName = "httpMaxBody"
+
+	// TimeoutOpt define the handler option key for specifying the maximum
+	// duration of the handler execution. The value must be a time.Duration. When
+	// the handler does not respond in time a 503 status code is sent.
+	TimeoutOpt nanux.HandlerOptName = "httpTimeout"
+
+	// ContentTypesOpt define the handler option key for specifying the content
+	// types accepted for the request body. The value must be a []string and
+	// patterns like "text/*" are allowed. Requests with a body of another
+	// content type are answered with a 415 status code.
+	ContentTypesOpt nanux.HandlerOptName = "httpContentTypes"
+
+	// StreamBodyOpt define the handler option key for streaming the request body
+	// of the route. The value must be a bool. When true, the body is not read in
+	// memory before calling the handler: req.Data is empty and the body must be
+	// read from BodyStream(req). The routes streaming their body must be added
+	// before the transporter runs.
+	StreamBodyOpt nanux.HandlerOptName = "httpStreamBody"
 )
 
 // Methods define available methods for handler
@@ -0,0 +1,77 @@
+package thttp
+
+import (
+	"strings"
+
+	"github.com/nanux-io/nanux"
+)
+
+// hasPathParams tells if the route has parameters, ie segments starting with
+// ":" (eg: /orders/:id)
+func hasPathParams(route string) bool {
+	return strings.Contains(route, "/:")
+}
+
+// matchPath return the parameters of the path if it matches the route. A
+// parameter matches a non-empty segment of the path.
+func matchPath(route, path string) (map[string]string, bool) {
+	routeSegments := strings.Split(route, "/")
+	pathSegments := strings.Split(path, "/")
+
+	if len(routeSegments) != len(pathSegments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+
+	for i, segment := range routeSegments {
+		if strings.HasPrefix(segment, ":") == true {
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+
+			params[segment[1:]] = pathSegments[i]
+		} else if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// findRoute return the handler of the route matching the path and the method,
+// with the parameters of the path. Routes without parameters take precedence,
+// then the routes with parameters are tried in the order they were added.
+func (t *Transporter) findRoute(path, method string) (routeHandler, map[string]string, bool) {
+	if rHandler, ok := t.routeHandlers[httpRoute{route: path, method: method}]; ok == true {
+		return rHandler, nil, true
+	}
+
+	for _, key := range t.paramRoutes {
+		if key.method != method {
+			continue
+		}
+
+		if params, ok := matchPath(key.route, path); ok == true {
+			return t.routeHandlers[key], params, true
+		}
+	}
+
+	return routeHandler{}, nil, false
+}
+
+// GetRoute return the route of the handler injected in `req.M["route"]`, with
+// its parameters (eg: /orders/:id)
+func GetRoute(req nanux.Request) string {
+	route, _ := req.M["route"].(string)
+
+	return route
+}
+
+// GetPathParams return the parameters of the path injected in
+// `req.M["pathParams"]` for the routes with parameters (eg: /orders/:id)
+func GetPathParams(req nanux.Request) map[string]string {
+	params, _ := req.M["pathParams"].(map[string]string)
+
+	return params
+}
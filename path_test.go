@@ -0,0 +1,96 @@
+package thttp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		route      string
+		path       string
+		wantParams map[string]string
+		wantOK     bool
+	}{
+		{name: "one parameter", route: "/orders/:id", path: "/orders/42", wantParams: map[string]string{"id": "42"}, wantOK: true},
+		{name: "several parameters", route: "/users/:user/orders/:id", path: "/users/alice/orders/42", wantParams: map[string]string{"user": "alice", "id": "42"}, wantOK: true},
+		{name: "empty parameter", route: "/orders/:id", path: "/orders/", wantOK: false},
+		{name: "other literal segment", route: "/orders/:id", path: "/users/42", wantOK: false},
+		{name: "more segments", route: "/orders/:id", path: "/orders/42/items", wantOK: false},
+		{name: "less segments", route: "/orders/:id/items", path: "/orders/42", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, ok := matchPath(tt.route, tt.path)
+
+			if ok != tt.wantOK || (ok == true && reflect.DeepEqual(params, tt.wantParams) == false) {
+				t.Errorf("matchPath() = %v, %v, want %v, %v", params, ok, tt.wantParams, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTransporter_findRoute(t *testing.T) {
+	tr := New("127.0.0.1:1234", false)
+
+	routes := []struct {
+		route string
+		resp  string
+	}{
+		{"/orders/new", "new"},
+		{"/orders/:id", "order"},
+		{"/orders/:id/items", "items"},
+	}
+
+	for _, r := range routes {
+		resp := r.resp
+		tHandler := nanux.THandler{
+			Fn:   func(nanux.Request) ([]byte, error) { return []byte(resp), nil },
+			Opts: nanux.HandlerOpts{MethodsOpt: Methods{Get: true}},
+		}
+
+		if err := tr.Handle(r.route, tHandler); err != nil {
+			t.Fatalf("Transporter.Handle() err = %v", err)
+		}
+	}
+
+	tests := []struct {
+		path       string
+		method     string
+		wantResp   string
+		wantParams map[string]string
+		wantOK     bool
+	}{
+		{path: "/orders/new", method: "GET", wantResp: "new", wantOK: true},
+		{path: "/orders/42", method: "GET", wantResp: "order", wantParams: map[string]string{"id": "42"}, wantOK: true},
+		{path: "/orders/42/items", method: "GET", wantResp: "items", wantParams: map[string]string{"id": "42"}, wantOK: true},
+		{path: "/orders/42", method: "POST", wantOK: false},
+		{path: "/users/42", method: "GET", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rHandler, params, ok := tr.findRoute(tt.path, tt.method)
+
+			if ok != tt.wantOK {
+				t.Fatalf("Transporter.findRoute() ok = %v, want %v", ok, tt.wantOK)
+			}
+
+			if ok == false {
+				return
+			}
+
+			if resp, _ := rHandler.fn(nanux.Request{}); string(resp) != tt.wantResp {
+				t.Errorf("Transporter.findRoute() handler response = %s, want %s", resp, tt.wantResp)
+			}
+
+			if reflect.DeepEqual(params, tt.wantParams) == false {
+				t.Errorf("Transporter.findRoute() params = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
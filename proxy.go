@@ -0,0 +1,241 @@
+package thttp
+
+import (
+	"net"
+	"strings"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// clientUserValue is the key of the client in the user values of the fasthttp
+// context
+const clientUserValue = "thttpClient"
+
+// Client describes the client which sent the request, as seen by the first
+// trusted proxy
+type Client struct {
+	IP     net.IP
+	Scheme string
+	Host   string
+}
+
+// WithTrustedProxies set the networks of the proxies in front of the
+// transporter (see ParseCIDRs). The Forwarded, X-Forwarded-For, X-Real-IP,
+// X-Forwarded-Proto and X-Forwarded-Host headers are only read when the
+// request comes from one of them.
+func WithTrustedProxies(proxies ...*net.IPNet) Option {
+	return func(t *Transporter) {
+		t.trustedProxies = append(t.trustedProxies, proxies...)
+	}
+}
+
+// ParseCIDRs parse networks in the CIDR notation (eg: "10.0.0.0/8"). A single
+// ip is parsed as a network containing only this ip.
+func ParseCIDRs(cidrs ...string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		if strings.IndexByte(cidr, '/') < 0 {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// GetClient return the client resolved by the transporter and injected in
+// `req.M["client"]`. The client of the connection is returned if there is
+// none.
+func GetClient(req nanux.Request) Client {
+	if client, ok := req.M["client"].(Client); ok == true {
+		return client
+	}
+
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return Client{}
+	}
+
+	return connectionClient(httpCtx)
+}
+
+// ClientIP return the ip of the client resolved by the transporter, or the ip
+// of the connection if the request has not been handled by a transporter
+func ClientIP(ctx *fasthttp.RequestCtx) net.IP {
+	if client, ok := ctx.UserValue(clientUserValue).(Client); ok == true {
+		return client.IP
+	}
+
+	return ctx.RemoteIP()
+}
+
+// connectionClient return the client of the connection, ignoring the
+// forwarding headers
+func connectionClient(ctx *fasthttp.RequestCtx) Client {
+	scheme := "http"
+
+	if ctx.IsTLS() == true {
+		scheme = "https"
+	}
+
+	return Client{IP: ctx.RemoteIP(), Scheme: scheme, Host: string(ctx.Host())}
+}
+
+// resolveClient return the client of the request. The forwarding headers are
+// read from the right, that is from the closest proxy, as long as the hops are
+// trusted proxies, so that a client can not spoof its ip, scheme or host by
+// sending these headers itself.
+func (t *Transporter) resolveClient(ctx *fasthttp.RequestCtx) Client {
+	client := connectionClient(ctx)
+
+	if t.trusted(client.IP) == false {
+		return client
+	}
+
+	if forwarded := headerValues(&ctx.Request.Header, "Forwarded"); len(forwarded) > 0 {
+		for i := len(forwarded) - 1; i >= 0 && t.trusted(client.IP) == true; i-- {
+			params := parseForwarded(forwarded[i])
+			ip := parseNode(params["for"])
+
+			if ip == nil {
+				break
+			}
+
+			client.IP = ip
+
+			if proto := params["proto"]; proto != "" {
+				client.Scheme = strings.ToLower(proto)
+			}
+
+			if host := params["host"]; host != "" {
+				client.Host = host
+			}
+		}
+
+		return client
+	}
+
+	// hop is the position, from the right, of the entry of the X-Forwarded-For
+	// header giving the ip of the client
+	hop := 0
+
+	if xff := headerValues(&ctx.Request.Header, "X-Forwarded-For"); len(xff) > 0 {
+		for i := len(xff) - 1; i >= 0 && t.trusted(client.IP) == true; i-- {
+			ip := parseNode(xff[i])
+
+			if ip == nil {
+				break
+			}
+
+			client.IP = ip
+			hop = len(xff) - 1 - i
+		}
+	} else if ip := parseNode(string(ctx.Request.Header.Peek("X-Real-IP"))); ip != nil {
+		client.IP = ip
+	}
+
+	// the scheme and the host are read at the same position as the ip, the
+	// entries on their left may come from the client. The proxies overwriting
+	// these headers instead of appending to them leave fewer entries, the one
+	// of the nearest proxy is then used.
+	if proto := forwardedValue(&ctx.Request.Header, "X-Forwarded-Proto", hop); proto != "" {
+		client.Scheme = strings.ToLower(proto)
+	}
+
+	if host := forwardedValue(&ctx.Request.Header, "X-Forwarded-Host", hop); host != "" {
+		client.Host = host
+	}
+
+	return client
+}
+
+// forwardedValue return the entry of the forwarding header at the position hop
+// from the right. If the header has not as many entries, the last one, set by
+// the nearest trusted proxy, is returned.
+func forwardedValue(h *fasthttp.RequestHeader, name string, hop int) string {
+	values := headerValues(h, name)
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	if hop >= len(values) {
+		return values[len(values)-1]
+	}
+
+	return values[len(values)-1-hop]
+}
+
+// trusted tells if the ip is one of a trusted proxy
+func (t *Transporter) trusted(ip net.IP) bool {
+	return containsIP(t.trustedProxies, ip)
+}
+
+// headerValues return the comma separated values of all the headers with the
+// specified name
+func headerValues(h *fasthttp.RequestHeader, name string) []string {
+	var values []string
+
+	h.VisitAll(func(key, value []byte) {
+		if strings.EqualFold(string(key), name) == false {
+			return
+		}
+
+		for _, v := range strings.Split(string(value), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	})
+
+	return values
+}
+
+// parseForwarded return the parameters of an element of the Forwarded header
+// (RFC 7239), eg: for=192.0.2.60;proto=https;host=example.com
+func parseForwarded(element string) map[string]string {
+	params := make(map[string]string)
+
+	for _, pair := range strings.Split(element, ";") {
+		i := strings.IndexByte(pair, '=')
+
+		if i < 0 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(pair[:i]))
+		params[key] = strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+	}
+
+	return params
+}
+
+// parseNode return the ip of a node which can have a port (eg: 192.0.2.60:80
+// or [2001:db8::1]:80). nil is returned for unknown or obfuscated nodes.
+func parseNode(node string) net.IP {
+	node = strings.TrimSpace(node)
+
+	if strings.HasPrefix(node, "[") == true {
+		if i := strings.IndexByte(node, ']'); i > 0 {
+			node = node[1:i]
+		}
+	} else if strings.Count(node, ":") == 1 {
+		node = node[:strings.IndexByte(node, ':')]
+	}
+
+	return net.ParseIP(node)
+}
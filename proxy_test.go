@@ -0,0 +1,192 @@
+package thttp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidrs   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "networks", cidrs: []string{"10.0.0.0/8", "2001:db8::/32"}, want: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{name: "single ips", cidrs: []string{"192.0.2.1", "2001:db8::1"}, want: []string{"192.0.2.1/32", "2001:db8::1/128"}},
+		{name: "invalid network", cidrs: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "invalid ip", cidrs: []string{"proxy"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networks, err := ParseCIDRs(tt.cidrs...)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCIDRs() err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(networks) != len(tt.want) {
+				t.Fatalf("ParseCIDRs() = %v, want %v", networks, tt.want)
+			}
+
+			for i, network := range networks {
+				if network.String() != tt.want[i] {
+					t.Errorf("ParseCIDRs()[%d] = %v, want %v", i, network, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTransporter_resolveClient(t *testing.T) {
+	proxies, _ := ParseCIDRs("10.0.0.0/8", "2001:db8::/32")
+
+	tests := []struct {
+		name     string
+		remoteIP string
+		headers  map[string]string
+		want     Client
+	}{
+		{
+			name:     "no forwarding header",
+			remoteIP: "10.0.0.1",
+			want:     Client{IP: net.ParseIP("10.0.0.1"), Scheme: "http", Host: "example.com"},
+		},
+		{
+			name:     "headers of an untrusted client are ignored",
+			remoteIP: "198.51.100.1",
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "https"},
+			want:     Client{IP: net.ParseIP("198.51.100.1"), Scheme: "http", Host: "example.com"},
+		},
+		{
+			name:     "x-forwarded-for",
+			remoteIP: "10.0.0.1",
+			headers: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7",
+				"X-Forwarded-Proto": "HTTPS",
+				"X-Forwarded-Host":  "api.example.com",
+			},
+			want: Client{IP: net.ParseIP("203.0.113.7"), Scheme: "https", Host: "api.example.com"},
+		},
+		{
+			name:     "x-forwarded-for spoofed by the client",
+			remoteIP: "10.0.0.1",
+			headers:  map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 10.0.0.2"},
+			want:     Client{IP: net.ParseIP("203.0.113.7"), Scheme: "http", Host: "example.com"},
+		},
+		{
+			name:     "x-forwarded-proto and host spoofed by the client",
+			remoteIP: "10.0.0.1",
+			headers: map[string]string{
+				"X-Forwarded-For":   "1.2.3.4, 203.0.113.7, 10.0.0.2",
+				"X-Forwarded-Proto": "https, http, https",
+				"X-Forwarded-Host":  "evil.com, api.example.com, internal",
+			},
+			want: Client{IP: net.ParseIP("203.0.113.7"), Scheme: "http", Host: "api.example.com"},
+		},
+		{
+			name:     "x-forwarded-proto and host overwritten by the nearest proxy",
+			remoteIP: "10.0.0.1",
+			headers: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7, 10.0.0.2",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "shop.example.com",
+			},
+			want: Client{IP: net.ParseIP("203.0.113.7"), Scheme: "https", Host: "shop.example.com"},
+		},
+		{
+			name:     "x-forwarded-proto and host with fewer entries than the hops",
+			remoteIP: "10.0.0.1",
+			headers: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7, 10.0.0.3, 10.0.0.2",
+				"X-Forwarded-Proto": "http, https",
+				"X-Forwarded-Host":  "evil.com, shop.example.com",
+			},
+			want: Client{IP: net.ParseIP("203.0.113.7"), Scheme: "https", Host: "shop.example.com"},
+		},
+		{
+			name:     "x-forwarded-for with only trusted proxies",
+			remoteIP: "10.0.0.1",
+			headers:  map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			want:     Client{IP: net.ParseIP("10.0.0.3"), Scheme: "http", Host: "example.com"},
+		},
+		{
+			name:     "x-forwarded-for with an invalid hop",
+			remoteIP: "10.0.0.1",
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.7, unknown, 10.0.0.2"},
+			want:     Client{IP: net.ParseIP("10.0.0.2"), Scheme: "http", Host: "example.com"},
+		},
+		{
+			name:     "x-real-ip",
+			remoteIP: "10.0.0.1",
+			headers:  map[string]string{"X-Real-IP": "203.0.113.7"},
+			want:     Client{IP: net.ParseIP("203.0.113.7"), Scheme: "http", Host: "example.com"},
+		},
+		{
+			name:     "forwarded",
+			remoteIP: "10.0.0.1",
+			headers: map[string]string{
+				"Forwarded":       `for=1.2.3.4, for=203.0.113.7;proto=https;host="api.example.com", for="[2001:db8::17]:4711";proto=http`,
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: Client{IP: net.ParseIP("203.0.113.7"), Scheme: "https", Host: "api.example.com"},
+		},
+		{
+			name:     "forwarded with an obfuscated node",
+			remoteIP: "10.0.0.1",
+			headers:  map[string]string{"Forwarded": "for=_hidden;proto=https"},
+			want:     Client{IP: net.ParseIP("10.0.0.1"), Scheme: "http", Host: "example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := New("127.0.0.1:1234", false, WithTrustedProxies(proxies...))
+
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP(tt.remoteIP)}, nil)
+			httpCtx.Request.Header.SetHost("example.com")
+
+			for name, value := range tt.headers {
+				httpCtx.Request.Header.Set(name, value)
+			}
+
+			got := tr.resolveClient(httpCtx)
+
+			if got.IP.Equal(tt.want.IP) == false || got.Scheme != tt.want.Scheme || got.Host != tt.want.Host {
+				t.Errorf("Transporter.resolveClient() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	httpCtx := &fasthttp.RequestCtx{}
+	httpCtx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP("10.0.0.1")}, nil)
+
+	if ip := ClientIP(httpCtx); ip.Equal(net.ParseIP("10.0.0.1")) == false {
+		t.Errorf("ClientIP() without resolved client = %v, want 10.0.0.1", ip)
+	}
+
+	if client := GetClient(nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}); client.IP.Equal(net.ParseIP("10.0.0.1")) == false {
+		t.Errorf("GetClient() without resolved client = %v, want 10.0.0.1", client.IP)
+	}
+
+	httpCtx.SetUserValue(clientUserValue, Client{IP: net.ParseIP("203.0.113.7")})
+
+	if ip := ClientIP(httpCtx); ip.Equal(net.ParseIP("203.0.113.7")) == false {
+		t.Errorf("ClientIP() = %v, want 203.0.113.7", ip)
+	}
+
+	if client := GetClient(nanux.Request{M: map[string]interface{}{"client": Client{IP: net.ParseIP("203.0.113.7")}}}); client.IP.Equal(net.ParseIP("203.0.113.7")) == false {
+		t.Errorf("GetClient() = %v, want 203.0.113.7", client.IP)
+	}
+
+	if client := GetClient(nanux.Request{M: map[string]interface{}{}}); client.IP != nil {
+		t.Errorf("GetClient() without context = %v, want nil", client.IP)
+	}
+}
@@ -0,0 +1,270 @@
+package thttp
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// RateLimitOpt define the handler option key for overriding, for a route, the
+// limit of the RateLimit middleware. The value must be of type thttp.Limit.
+const RateLimitOpt nanux.HandlerOptName = "httpRateLimit"
+
+// Limit define a token bucket: a client can make Burst requests at once and
+// the bucket is refilled at Rate requests per second. A Limit with a Rate of 0
+// disables the rate limiting and Burst is at least 1.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// errInvalidLimit is returned by the stores for a limit which does not refill
+// its buckets
+var errInvalidLimit = errors.New("RateLimitStore : the rate and the burst of the limit must be positive")
+
+// validate check that the limit can be used by the stores. The RateLimit
+// middleware does not use the stores for the limits with a Rate of 0.
+func (l Limit) validate() error {
+	if (l.Rate > 0) == false || math.IsInf(l.Rate, 1) == true || l.Burst < 1 {
+		return errInvalidLimit
+	}
+
+	return nil
+}
+
+// RateLimitResult is the state of a bucket after a request has been counted
+type RateLimitResult struct {
+	// Allowed tells if the request can be processed
+	Allowed bool
+	// Remaining is the number of requests which can be made immediately
+	Remaining int
+	// Reset is the duration after which the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the duration after which a rejected request can be retried
+	RetryAfter time.Duration
+}
+
+// RateLimitConfig define the configuration of the RateLimit middleware
+type RateLimitConfig struct {
+	// Limit is the limit applied to each client. It can be overridden by route
+	// with the RateLimitOpt handler option.
+	Limit Limit
+	// KeyFunc return the key identifying the client of the request. Default to
+	// KeyByIP.
+	KeyFunc func(ctx *fasthttp.RequestCtx) string
+	// Store holds the buckets. Default to a MemoryRateLimitStore cleaned up
+	// every minute.
+	Store RateLimitStore
+}
+
+// RateLimitStore holds the token buckets of the clients. A store shared by
+// several instances of a service (eg: RedisRateLimitStore) makes the limits
+// global to all the instances.
+type RateLimitStore interface {
+	// Take a token from the bucket identified by the key. The bucket is created
+	// full if it does not exist.
+	Take(key string, limit Limit, now time.Time) (RateLimitResult, error)
+}
+
+// KeyByIP identify the client by its ip, resolved from the forwarding headers
+// of the trusted proxies (see WithTrustedProxies)
+func KeyByIP(ctx *fasthttp.RequestCtx) string {
+	return ClientIP(ctx).String()
+}
+
+// KeyByHeader return a key function identifying the client by the value of the
+// specified header (eg: an API key)
+func KeyByHeader(header string) func(ctx *fasthttp.RequestCtx) string {
+	return func(ctx *fasthttp.RequestCtx) string {
+		return string(ctx.Request.Header.Peek(header))
+	}
+}
+
+// RateLimit return a middleware limiting the rate of the requests of each
+// client with a token bucket. Rejected requests are answered with a 429 status
+// code and a Retry-After header. The RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers are set on all the responses.
+//
+// The buckets are shared by all the routes using the same middleware, except
+// for the routes defining their own limit with the RateLimitOpt option, which
+// have their own buckets whatever the values of their parameters. If the
+// store fails, the request is allowed.
+func RateLimit(cfg RateLimitConfig) nanux.Middleware {
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = KeyByIP
+	}
+
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore(time.Minute)
+	}
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			limit := cfg.Limit
+			key := cfg.KeyFunc(httpCtx)
+
+			// a route with its own limit has its own buckets, shared by all the
+			// paths matching its parameters
+			if routeLimit, ok := GetHandlerOpts(req)[RateLimitOpt].(Limit); ok == true {
+				route := GetRoute(req)
+
+				if route == "" {
+					route = string(httpCtx.Path())
+				}
+
+				limit = routeLimit
+				key = route + "\x00" + key
+			}
+
+			if limit.Rate <= 0 {
+				return fn(ctx, req)
+			}
+
+			if limit.Burst < 1 {
+				limit.Burst = 1
+			}
+
+			res, err := cfg.Store.Take(key, limit, time.Now())
+
+			if err != nil {
+				GetLogger(req).Error().Err(err).Msg("RateLimit : could not take a token from the store")
+
+				return fn(ctx, req)
+			}
+
+			setRateLimitHeaders(&httpCtx.Response.Header, limit, res)
+
+			if res.Allowed == false {
+				httpCtx.Response.Header.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				httpCtx.SetStatusCode(fasthttp.StatusTooManyRequests)
+
+				return nil, nil
+			}
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// setRateLimitHeaders set the RateLimit headers of the response
+func setRateLimitHeaders(header *fasthttp.ResponseHeader, limit Limit, res RateLimitResult) {
+	header.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+// ceilSeconds return the duration in seconds rounded up
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// takeToken refill a bucket containing tokens which was last updated at last
+// and take a token from it if possible. The new number of tokens is returned
+// with the result.
+func takeToken(tokens float64, last time.Time, limit Limit, now time.Time) (float64, RateLimitResult) {
+	allowed := false
+
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(limit.Burst), tokens+elapsed*limit.Rate)
+	}
+
+	if tokens >= 1 {
+		tokens--
+		allowed = true
+	}
+
+	return tokens, newRateLimitResult(allowed, tokens, limit)
+}
+
+// newRateLimitResult compute the result of a request from the number of tokens
+// left in the bucket once the request has been counted
+func newRateLimitResult(allowed bool, tokens float64, limit Limit) RateLimitResult {
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second)),
+	}
+
+	if allowed == false {
+		res.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+
+	return res
+}
+
+// bucket is a token bucket of a client
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// MemoryRateLimitStore holds the token buckets in memory. The limits are thus
+// only enforced per instance of the service.
+type MemoryRateLimitStore struct {
+	mu              sync.Mutex
+	buckets         map[string]*bucket
+	cleanupInterval time.Duration
+	lastCleanup     time.Time
+}
+
+// NewMemoryRateLimitStore return a store removing, at the specified interval,
+// the buckets of the clients which have not made requests for a while
+func NewMemoryRateLimitStore(cleanupInterval time.Duration) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:         make(map[string]*bucket),
+		cleanupInterval: cleanupInterval,
+		lastCleanup:     time.Now(),
+	}
+}
+
+// Take a token from the bucket associated to the key
+func (m *MemoryRateLimitStore) Take(key string, limit Limit, now time.Time) (RateLimitResult, error) {
+	if err := limit.validate(); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastCleanup) >= m.cleanupInterval {
+		m.cleanup(now)
+	}
+
+	b, ok := m.buckets[key]
+
+	if ok == false {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		m.buckets[key] = b
+	}
+
+	var res RateLimitResult
+	b.tokens, res = takeToken(b.tokens, b.last, limit, now)
+	b.last = now
+	b.limit = limit
+
+	return res, nil
+}
+
+// cleanup remove the buckets which are full again. They are the same as new
+// buckets so there is no need to keep them.
+func (m *MemoryRateLimitStore) cleanup(now time.Time) {
+	for key, b := range m.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+
+	m.lastCleanup = now
+}
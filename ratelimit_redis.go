@@ -0,0 +1,107 @@
+package thttp
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// takeTokenScript is the Lua version of takeToken. It is executed atomically by
+// the server, with the time of the server so that the clocks of the instances
+// of the service do not need to be synchronized. The bucket expires once it is
+// full again.
+const takeTokenScript = `
+redis.replicate_commands()
+
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(bucket[1])
+local last = tonumber(bucket[2])
+
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) / 1000 * rate)
+	last = now
+end
+
+local allowed = 0
+
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(last))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+
+return {allowed, tostring(tokens)}
+`
+
+// RedisRateLimitStore holds the token buckets in a server speaking the Redis
+// protocol so that the limits are shared by all the instances of a service.
+type RedisRateLimitStore struct {
+	client *redisClient
+	prefix string
+}
+
+// NewRedisRateLimitStore return a store connecting to the server when needed.
+// The keys of the buckets are prefixed with "thttp:ratelimit:".
+func NewRedisRateLimitStore(cfg RedisConfig) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		client: newRedisClient(cfg),
+		prefix: "thttp:ratelimit:",
+	}
+}
+
+// Take a token from the bucket associated to the key. The buckets are refilled
+// according to the time of the server, now is ignored.
+func (s *RedisRateLimitStore) Take(key string, limit Limit, now time.Time) (RateLimitResult, error) {
+	if err := limit.validate(); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	reply, err := s.client.do(
+		"EVAL", takeTokenScript, "1", s.prefix+key,
+		strconv.FormatFloat(limit.Rate, 'f', -1, 64),
+		strconv.Itoa(limit.Burst),
+	)
+
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	values, ok := reply.([]interface{})
+
+	if ok == false || len(values) != 2 {
+		return RateLimitResult{}, errors.New("RedisRateLimitStore : unexpected reply of the server")
+	}
+
+	allowed, ok := values[0].(int64)
+	tokensStr, ok2 := values[1].(string)
+
+	if ok == false || ok2 == false {
+		return RateLimitResult{}, errors.New("RedisRateLimitStore : unexpected reply of the server")
+	}
+
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return newRateLimitResult(allowed == 1, tokens, limit), nil
+}
+
+// Close the idle connections to the server
+func (s *RedisRateLimitStore) Close() error {
+	s.client.close()
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package thttp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisRateLimitStore_Take(t *testing.T) {
+	server, err := miniredis.Run()
+
+	if err != nil {
+		t.Fatalf("could not start redis server - %s", err)
+	}
+
+	defer server.Close()
+
+	now := time.Now()
+	limit := Limit{Rate: 2, Burst: 2}
+
+	tests := []struct {
+		name    string
+		key     string
+		now     time.Time
+		wantRes RateLimitResult
+	}{
+		{
+			name:    "new bucket",
+			key:     "client1",
+			now:     now,
+			wantRes: RateLimitResult{Allowed: true, Remaining: 1, Reset: 500 * time.Millisecond},
+		},
+		{
+			name:    "last token",
+			key:     "client1",
+			now:     now,
+			wantRes: RateLimitResult{Allowed: true, Remaining: 0, Reset: time.Second},
+		},
+		{
+			name:    "empty bucket",
+			key:     "client1",
+			now:     now,
+			wantRes: RateLimitResult{Allowed: false, Remaining: 0, Reset: time.Second, RetryAfter: 500 * time.Millisecond},
+		},
+		{
+			name:    "refilled bucket",
+			key:     "client1",
+			now:     now.Add(500 * time.Millisecond),
+			wantRes: RateLimitResult{Allowed: true, Remaining: 0, Reset: time.Second},
+		},
+		{
+			name:    "bucket of another client",
+			key:     "client2",
+			now:     now,
+			wantRes: RateLimitResult{Allowed: true, Remaining: 1, Reset: 500 * time.Millisecond},
+		},
+	}
+
+	store := NewRedisRateLimitStore(RedisConfig{Addr: server.Addr()})
+	defer store.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the buckets are refilled with the time of the server, not the one of
+			// the instance
+			server.SetTime(tt.now)
+			gotRes, err := store.Take(tt.key, limit, now.Add(time.Hour))
+
+			if err != nil {
+				t.Fatalf("RedisRateLimitStore.Take() err = %v", err)
+			}
+
+			if gotRes != tt.wantRes {
+				t.Errorf("RedisRateLimitStore.Take() = %+v, want %+v", gotRes, tt.wantRes)
+			}
+		})
+	}
+
+	if ttl := server.TTL("thttp:ratelimit:client1"); ttl <= 0 {
+		t.Errorf("RedisRateLimitStore.Take() must set an expiration on the bucket, got ttl %v", ttl)
+	}
+}
+
+func TestRedisRateLimitStore_invalidLimit(t *testing.T) {
+	store := NewRedisRateLimitStore(RedisConfig{Addr: "127.0.0.1:1"})
+	defer store.Close()
+
+	for _, limit := range []Limit{{Rate: 0, Burst: 1}, {Rate: -1, Burst: 1}, {Rate: 1, Burst: 0}} {
+		if _, err := store.Take("client", limit, time.Now()); err != errInvalidLimit {
+			t.Errorf("RedisRateLimitStore.Take(%+v) err = %v, want %v", limit, err, errInvalidLimit)
+		}
+	}
+}
+
+func TestRedisRateLimitStore_connection(t *testing.T) {
+	server, err := miniredis.Run()
+
+	if err != nil {
+		t.Fatalf("could not start redis server - %s", err)
+	}
+
+	defer server.Close()
+
+	server.RequireAuth("secret")
+
+	tests := []struct {
+		name    string
+		cfg     RedisConfig
+		wantErr bool
+	}{
+		{name: "valid password and database", cfg: RedisConfig{Addr: server.Addr(), Password: "secret", DB: 2}},
+		{name: "missing password", cfg: RedisConfig{Addr: server.Addr()}, wantErr: true},
+		{name: "wrong password", cfg: RedisConfig{Addr: server.Addr(), Password: "wrong"}, wantErr: true},
+		{name: "server not reachable", cfg: RedisConfig{Addr: "127.0.0.1:1", Timeout: 50 * time.Millisecond}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewRedisRateLimitStore(tt.cfg)
+			defer store.Close()
+
+			_, err := store.Take("client", Limit{Rate: 1, Burst: 1}, time.Now())
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RedisRateLimitStore.Take() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if server.DB(2).Exists("thttp:ratelimit:client") == false {
+		t.Error("RedisRateLimitStore.Take() must use the selected database")
+	}
+}
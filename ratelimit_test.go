@@ -0,0 +1,229 @@
+package thttp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestTakeToken(t *testing.T) {
+	now := time.Now()
+	limit := Limit{Rate: 2, Burst: 4}
+
+	tests := []struct {
+		name       string
+		tokens     float64
+		last       time.Time
+		wantTokens float64
+		wantRes    RateLimitResult
+	}{
+		{
+			name:       "full bucket",
+			tokens:     4,
+			last:       now,
+			wantTokens: 3,
+			wantRes:    RateLimitResult{Allowed: true, Remaining: 3, Reset: 500 * time.Millisecond},
+		},
+		{
+			name:       "refilled bucket",
+			tokens:     0,
+			last:       now.Add(-time.Second),
+			wantTokens: 1,
+			wantRes:    RateLimitResult{Allowed: true, Remaining: 1, Reset: 1500 * time.Millisecond},
+		},
+		{
+			name:       "bucket not refilled above the burst",
+			tokens:     3,
+			last:       now.Add(-time.Hour),
+			wantTokens: 3,
+			wantRes:    RateLimitResult{Allowed: true, Remaining: 3, Reset: 500 * time.Millisecond},
+		},
+		{
+			name:       "empty bucket",
+			tokens:     0.5,
+			last:       now,
+			wantTokens: 0.5,
+			wantRes:    RateLimitResult{Allowed: false, Remaining: 0, Reset: 1750 * time.Millisecond, RetryAfter: 250 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTokens, gotRes := takeToken(tt.tokens, tt.last, limit, now)
+
+			if gotTokens != tt.wantTokens {
+				t.Errorf("takeToken() tokens = %v, want %v", gotTokens, tt.wantTokens)
+			}
+
+			if gotRes != tt.wantRes {
+				t.Errorf("takeToken() res = %+v, want %+v", gotRes, tt.wantRes)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStore_cleanup(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRateLimitStore(time.Minute)
+	limit := Limit{Rate: 1, Burst: 10}
+
+	store.Take("idle", limit, now.Add(-time.Hour))
+	store.Take("active", limit, now)
+
+	store.cleanup(now)
+
+	if _, ok := store.buckets["idle"]; ok == true {
+		t.Error("MemoryRateLimitStore.cleanup() must remove the full buckets")
+	}
+
+	if _, ok := store.buckets["active"]; ok == false {
+		t.Error("MemoryRateLimitStore.cleanup() must keep the buckets which are not full")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             RateLimitConfig
+		opts            nanux.HandlerOpts
+		remoteIPs       []string
+		wantStatusCodes []int
+		wantRemaining   string
+	}{
+		{
+			name:            "requests within the burst",
+			cfg:             RateLimitConfig{Limit: Limit{Rate: 1, Burst: 2}},
+			remoteIPs:       []string{"10.0.0.1", "10.0.0.1"},
+			wantStatusCodes: []int{200, 200},
+			wantRemaining:   "0",
+		},
+		{
+			name:            "requests exceeding the burst",
+			cfg:             RateLimitConfig{Limit: Limit{Rate: 1, Burst: 2}},
+			remoteIPs:       []string{"10.0.0.1", "10.0.0.1", "10.0.0.1"},
+			wantStatusCodes: []int{200, 200, 429},
+			wantRemaining:   "0",
+		},
+		{
+			name:            "clients have their own bucket",
+			cfg:             RateLimitConfig{Limit: Limit{Rate: 1, Burst: 1}},
+			remoteIPs:       []string{"10.0.0.1", "10.0.0.2"},
+			wantStatusCodes: []int{200, 200},
+			wantRemaining:   "0",
+		},
+		{
+			name:            "limit overridden by the route",
+			cfg:             RateLimitConfig{Limit: Limit{Rate: 1, Burst: 1}},
+			opts:            nanux.HandlerOpts{RateLimitOpt: Limit{Rate: 1, Burst: 3}},
+			remoteIPs:       []string{"10.0.0.1", "10.0.0.1"},
+			wantStatusCodes: []int{200, 200},
+			wantRemaining:   "1",
+		},
+		{
+			name:            "limit disabled by the route",
+			cfg:             RateLimitConfig{Limit: Limit{Rate: 1, Burst: 1}},
+			opts:            nanux.HandlerOpts{RateLimitOpt: Limit{}},
+			remoteIPs:       []string{"10.0.0.1", "10.0.0.1"},
+			wantStatusCodes: []int{200, 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := RateLimit(tt.cfg)
+			fn := mw(func(*interface{}, nanux.Request) ([]byte, error) {
+				return []byte("response"), nil
+			})
+
+			for i, remoteIP := range tt.remoteIPs {
+				httpCtx := &fasthttp.RequestCtx{}
+				httpCtx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP(remoteIP)}, nil)
+				req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx, "handlerOpts": tt.opts}}
+
+				res, err := fn(nil, req)
+
+				if err != nil {
+					t.Fatalf("RateLimit() - error occured when calling handler - %s", err)
+				}
+
+				statusCode := httpCtx.Response.StatusCode()
+
+				if statusCode != tt.wantStatusCodes[i] {
+					t.Fatalf("RateLimit() - request %d status code = %v, want %v", i, statusCode, tt.wantStatusCodes[i])
+				}
+
+				if statusCode == 429 && (res != nil || len(httpCtx.Response.Header.Peek("Retry-After")) == 0) {
+					t.Errorf("RateLimit() - rejected request %d must not call the handler and must have a Retry-After header", i)
+				}
+
+				if i == len(tt.remoteIPs)-1 {
+					if remaining := string(httpCtx.Response.Header.Peek("RateLimit-Remaining")); remaining != tt.wantRemaining {
+						t.Errorf("RateLimit() - RateLimit-Remaining = %q, want %q", remaining, tt.wantRemaining)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKeyByHeader(t *testing.T) {
+	httpCtx := &fasthttp.RequestCtx{}
+	httpCtx.Request.Header.Set("X-API-Key", "key")
+
+	if got := KeyByHeader("X-API-Key")(httpCtx); got != "key" {
+		t.Errorf("KeyByHeader() = %v, want %v", got, "key")
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(string, Limit, time.Time) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store unavailable")
+}
+
+func TestRateLimit_storeError(t *testing.T) {
+	fn := RateLimit(RateLimitConfig{Limit: Limit{Rate: 1, Burst: 1}, Store: failingRateLimitStore{}})(
+		func(*interface{}, nanux.Request) ([]byte, error) {
+			return []byte("response"), nil
+		},
+	)
+
+	httpCtx := &fasthttp.RequestCtx{}
+	res, err := fn(nil, nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}})
+
+	if err != nil || string(res) != "response" {
+		t.Errorf("RateLimit() - request must be allowed when the store fails, got %s, %v", res, err)
+	}
+}
+
+func TestRateLimit_routeWithParameters(t *testing.T) {
+	fn := RateLimit(RateLimitConfig{Limit: Limit{Rate: 1, Burst: 10}})(
+		func(*interface{}, nanux.Request) ([]byte, error) {
+			return []byte("response"), nil
+		},
+	)
+
+	wantStatusCodes := []int{200, 429}
+
+	for i, path := range []string{"/orders/1", "/orders/2"} {
+		req := NewRequest(RequestConfig{
+			URI:   path,
+			Route: "/orders/:id",
+		})
+		req.M["handlerOpts"] = nanux.HandlerOpts{RateLimitOpt: Limit{Rate: 1, Burst: 1}}
+
+		if _, err := fn(nil, req); err != nil {
+			t.Fatalf("RateLimit() - error occured when calling handler - %s", err)
+		}
+
+		httpCtx, _ := GetHTTPCtx(req)
+
+		if statusCode := httpCtx.Response.StatusCode(); statusCode != wantStatusCodes[i] {
+			t.Errorf("RateLimit() - request on %s status code = %v, want %v", path, statusCode, wantStatusCodes[i])
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package thttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisConfig define how to connect to a server speaking the Redis protocol
+type RedisConfig struct {
+	// Addr is the address of the server. Default to 127.0.0.1:6379.
+	Addr string
+	// Password is sent with the AUTH command if it is not empty
+	Password string
+	// DB is the database selected on the connections
+	DB int
+	// Timeout is the timeout of the connection and of each command. Default to
+	// 1 second.
+	Timeout time.Duration
+	// PoolSize is the maximum number of idle connections kept open. Default to
+	// 10.
+	PoolSize int
+}
+
+// redisError is an error reply sent by the server. The connection is still
+// usable after such an error.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisClient is a minimal client of the Redis protocol (RESP) with a pool of
+// connections
+type redisClient struct {
+	cfg  RedisConfig
+	pool chan *redisConn
+}
+
+func newRedisClient(cfg RedisConfig) *redisClient {
+	if cfg.Addr == "" {
+		cfg.Addr = "127.0.0.1:6379"
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+
+	if cfg.PoolSize == 0 {
+		cfg.PoolSize = 10
+	}
+
+	return &redisClient{
+		cfg:  cfg,
+		pool: make(chan *redisConn, cfg.PoolSize),
+	}
+}
+
+// do send the command to the server and return its reply. The reply is a
+// string, an int64, nil or a []interface{} of these types.
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	conn, err := c.get()
+
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(c.cfg.Timeout, args...)
+
+	if _, ok := err.(redisError); err != nil && ok == false {
+		conn.Close()
+
+		return nil, err
+	}
+
+	c.put(conn)
+
+	return reply, err
+}
+
+// close the idle connections of the pool
+func (c *redisClient) close() {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// get an idle connection from the pool or open a new one
+func (c *redisClient) get() (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", c.cfg.Addr, c.cfg.Timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{Conn: netConn, r: bufio.NewReader(netConn)}
+
+	if c.cfg.Password != "" {
+		if _, err := conn.do(c.cfg.Timeout, "AUTH", c.cfg.Password); err != nil {
+			conn.Close()
+
+			return nil, err
+		}
+	}
+
+	if c.cfg.DB != 0 {
+		if _, err := conn.do(c.cfg.Timeout, "SELECT", strconv.Itoa(c.cfg.DB)); err != nil {
+			conn.Close()
+
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// put the connection back in the pool or close it if the pool is full
+func (c *redisClient) put(conn *redisConn) {
+	select {
+	case c.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// redisConn is a connection to the server
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// do write the command as an array of bulk strings and read the reply
+func (c *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	cmd := make([]byte, 0, 64)
+	cmd = append(cmd, '*')
+	cmd = strconv.AppendInt(cmd, int64(len(args)), 10)
+	cmd = append(cmd, '\r', '\n')
+
+	for _, arg := range args {
+		cmd = append(cmd, '$')
+		cmd = strconv.AppendInt(cmd, int64(len(arg)), 10)
+		cmd = append(cmd, '\r', '\n')
+		cmd = append(cmd, arg...)
+		cmd = append(cmd, '\r', '\n')
+	}
+
+	if _, err := c.Write(cmd); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+// readReply read a reply of the server
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("Redis : malformed reply")
+	}
+
+	prefix, value := line[0], line[1:len(line)-2]
+
+	switch prefix {
+	case '+':
+		return value, nil
+
+	case '-':
+		return nil, redisError(value)
+
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+
+	case '$':
+		size, err := strconv.Atoi(value)
+
+		if err != nil || size < 0 {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+
+		return string(buf[:size]), nil
+
+	case '*':
+		size, err := strconv.Atoi(value)
+
+		if err != nil || size < 0 {
+			return nil, err
+		}
+
+		values := make([]interface{}, size)
+
+		for i := range values {
+			if values[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("Redis : unknown reply type %q", prefix)
+}
@@ -0,0 +1,148 @@
+package thttp
+
+import (
+	"reflect"
+
+	"github.com/nanux-io/nanux"
+)
+
+// Query return the first value of the query string argument, or an empty
+// string if it is not set
+func Query(req nanux.Request, name string) string {
+	return first(queryValues(req, name))
+}
+
+// QueryValues return all the values of the query string argument
+func QueryValues(req nanux.Request, name string) []string {
+	return queryValues(req, name)
+}
+
+// Header return the value of the request header, or an empty string if it is
+// not set
+func Header(req nanux.Request, name string) string {
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return ""
+	}
+
+	return string(httpCtx.Request.Header.Peek(name))
+}
+
+// Param return the value of the path parameter (eg: "id" for the route
+// /orders/:id), or an empty string if the route has no such parameter
+func Param(req nanux.Request, name string) string {
+	return GetPathParams(req)[name]
+}
+
+// Cookie return the value of the request cookie, or an empty string if it is
+// not set
+func Cookie(req nanux.Request, name string) string {
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return ""
+	}
+
+	return string(httpCtx.Request.Header.Cookie(name))
+}
+
+// QueryAs return the query string argument parsed in the type of def, or def
+// if it is not set. Slices receive all the values of the argument, eg:
+// thttp.QueryAs(req, "tag", []string{}) for ?tag=a&tag=b. A 400 status code
+// error is returned when the value can not be parsed.
+//
+// Strings, booleans, numbers, time.Duration, time.Time (RFC 3339), pointers
+// and slices of them are supported.
+func QueryAs[T any](req nanux.Request, name string, def T) (T, error) {
+	if _, err := GetHTTPCtx(req); err != nil {
+		return def, err
+	}
+
+	return valueAs(queryValues(req, name), def, "query", name)
+}
+
+// HeaderAs return the request header parsed in the type of def, or def if it
+// is not set. Slices receive the comma separated values of all the headers
+// with this name. See QueryAs for the supported types.
+func HeaderAs[T any](req nanux.Request, name string, def T) (T, error) {
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return def, err
+	}
+
+	var values []string
+
+	if reflect.TypeOf(&def).Elem().Kind() == reflect.Slice {
+		values = headerValues(&httpCtx.Request.Header, name)
+	} else if value := httpCtx.Request.Header.Peek(name); len(value) > 0 {
+		values = []string{string(value)}
+	}
+
+	return valueAs(values, def, "header", name)
+}
+
+// ParamAs return the path parameter parsed in the type of def, or def if the
+// route has no such parameter. See QueryAs for the supported types.
+func ParamAs[T any](req nanux.Request, name string, def T) (T, error) {
+	var values []string
+
+	if value, ok := GetPathParams(req)[name]; ok == true {
+		values = []string{value}
+	}
+
+	return valueAs(values, def, "path parameter", name)
+}
+
+// CookieAs return the request cookie parsed in the type of def, or def if it
+// is not set. See QueryAs for the supported types.
+func CookieAs[T any](req nanux.Request, name string, def T) (T, error) {
+	if _, err := GetHTTPCtx(req); err != nil {
+		return def, err
+	}
+
+	var values []string
+
+	if value := Cookie(req, name); value != "" {
+		values = []string{value}
+	}
+
+	return valueAs(values, def, "cookie", name)
+}
+
+// queryValues return the values of the query string argument
+func queryValues(req nanux.Request, name string) []string {
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return nil
+	}
+
+	return argsLookup(httpCtx.QueryArgs())(name)
+}
+
+// valueAs parse the values in the type of def, or return def if there are no
+// values
+func valueAs[T any](values []string, def T, source, name string) (T, error) {
+	if len(values) == 0 {
+		return def, nil
+	}
+
+	var v T
+
+	if err := setField(reflect.ValueOf(&v).Elem(), values); err != nil {
+		return def, &Error{Status: 400, Message: "Invalid value for " + source + " " + name}
+	}
+
+	return v, nil
+}
+
+// first return the first value or an empty string
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
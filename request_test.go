@@ -0,0 +1,145 @@
+package thttp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func newTestRequest() nanux.Request {
+	httpCtx := &fasthttp.RequestCtx{}
+	httpCtx.Request.SetRequestURI("/orders/42?limit=10&tag=a&tag=b&gift=yes&since=2020-01-02T03:04:05Z")
+	httpCtx.Request.Header.Set("X-Request-ID", "abc")
+	httpCtx.Request.Header.Set("X-Retry", "3")
+	httpCtx.Request.Header.Add("X-Tags", "a, b")
+	httpCtx.Request.Header.Add("X-Tags", "c")
+	httpCtx.Request.Header.Set("If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT")
+	httpCtx.Request.Header.SetCookie("session", "s1")
+	httpCtx.Request.Header.SetCookie("visits", "7")
+
+	return nanux.Request{
+		M: map[string]interface{}{
+			"httpCtx":    httpCtx,
+			"pathParams": map[string]string{"id": "42", "slug": "book"},
+		},
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	req := newTestRequest()
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "query", got: Query(req, "limit"), want: "10"},
+		{name: "first value of query", got: Query(req, "tag"), want: "a"},
+		{name: "missing query", got: Query(req, "offset"), want: ""},
+		{name: "header", got: Header(req, "x-request-id"), want: "abc"},
+		{name: "header with comma", got: Header(req, "If-Modified-Since"), want: "Mon, 02 Jan 2006 15:04:05 GMT"},
+		{name: "param", got: Param(req, "slug"), want: "book"},
+		{name: "missing param", got: Param(req, "user"), want: ""},
+		{name: "cookie", got: Cookie(req, "session"), want: "s1"},
+		{name: "without http ctx", got: Query(nanux.Request{}, "limit"), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+
+	if values := QueryValues(req, "tag"); reflect.DeepEqual(values, []string{"a", "b"}) == false {
+		t.Errorf("QueryValues() = %v, want [a b]", values)
+	}
+}
+
+func TestTypedAccessors(t *testing.T) {
+	req := newTestRequest()
+	since := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		get        func() (interface{}, error)
+		want       interface{}
+		wantStatus int
+	}{
+		{
+			name: "query int",
+			get:  func() (interface{}, error) { return QueryAs(req, "limit", 20) },
+			want: 10,
+		},
+		{
+			name: "query default",
+			get:  func() (interface{}, error) { return QueryAs(req, "offset", 20) },
+			want: 20,
+		},
+		{
+			name: "query slice",
+			get:  func() (interface{}, error) { return QueryAs(req, "tag", []string{}) },
+			want: []string{"a", "b"},
+		},
+		{
+			name: "query time",
+			get:  func() (interface{}, error) { return QueryAs(req, "since", time.Time{}) },
+			want: since,
+		},
+		{
+			name:       "query invalid bool",
+			get:        func() (interface{}, error) { return QueryAs(req, "gift", false) },
+			want:       false,
+			wantStatus: 400,
+		},
+		{
+			name: "header int",
+			get:  func() (interface{}, error) { return HeaderAs(req, "X-Retry", 0) },
+			want: 3,
+		},
+		{
+			name: "header slice",
+			get:  func() (interface{}, error) { return HeaderAs(req, "X-Tags", []string(nil)) },
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "param int",
+			get:  func() (interface{}, error) { return ParamAs(req, "id", 0) },
+			want: 42,
+		},
+		{
+			name:       "param invalid int",
+			get:        func() (interface{}, error) { return ParamAs(req, "slug", 0) },
+			want:       0,
+			wantStatus: 400,
+		},
+		{
+			name: "cookie int",
+			get:  func() (interface{}, error) { return CookieAs(req, "visits", 0) },
+			want: 7,
+		},
+		{
+			name: "cookie pointer",
+			get:  func() (interface{}, error) { return CookieAs[*int](req, "missing", nil) },
+			want: (*int)(nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get()
+
+			if (err != nil) != (tt.wantStatus != 0) || (err != nil && errorStatusCode(err) != tt.wantStatus) {
+				t.Fatalf("err = %v, want status %d", err, tt.wantStatus)
+			}
+
+			if reflect.DeepEqual(got, tt.want) == false {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package thttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/nanux-io/nanux"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+)
+
+// Response carries the status code, the headers and the cookies of the
+// response of a handler without depending on fasthttp. The transporter
+// injects one in `req.M["response"]` and applies it to the response once the
+// handler returns, before the error handling: the status code of an error
+// takes precedence over the one of the Response.
+type Response struct {
+	status  int
+	header  http.Header
+	cookies []*http.Cookie
+}
+
+// NewResponse return an empty response
+func NewResponse() *Response {
+	return &Response{header: make(http.Header)}
+}
+
+// GetResponse return the response injected by the transporter in the nanux
+// request. If there is none, a new one is injected when possible.
+func GetResponse(req nanux.Request) *Response {
+	if resp, ok := req.M["response"].(*Response); ok == true {
+		return resp
+	}
+
+	resp := NewResponse()
+
+	if req.M != nil {
+		req.M["response"] = resp
+	}
+
+	return resp
+}
+
+// SetStatus set the status code of the response
+func (r *Response) SetStatus(statusCode int) {
+	r.status = statusCode
+}
+
+// Status return the status code set on the response, or 0 if it has not been
+// set
+func (r *Response) Status() int {
+	return r.status
+}
+
+// Header return the headers of the response, which can be modified
+func (r *Response) Header() http.Header {
+	return r.header
+}
+
+// SetCookie add a cookie to the response. A cookie with a negative MaxAge
+// deletes the cookie of the client.
+func (r *Response) SetCookie(cookie *http.Cookie) {
+	r.cookies = append(r.cookies, cookie)
+}
+
+// Cookies return the cookies added to the response
+func (r *Response) Cookies() []*http.Cookie {
+	return r.cookies
+}
+
+// Redirect set the Location header and the status code of the response, eg:
+// resp.Redirect("/orders/42", 201) for a created resource
+func (r *Response) Redirect(location string, statusCode int) {
+	r.header.Set("Location", location)
+	r.status = statusCode
+}
+
+// apply set the status code, the headers and the cookies on the fasthttp
+// response
+func (r *Response) apply(ctx *fasthttp.RequestCtx) {
+	if r.status != 0 {
+		ctx.SetStatusCode(r.status)
+	}
+
+	for name, values := range r.header {
+		// the Vary values are merged with the ones already set (eg: by Compress)
+		// so that the caches keep varying on them
+		if strings.EqualFold(name, "Vary") == true {
+			for _, value := range values {
+				for _, v := range strings.Split(value, ",") {
+					if v = strings.TrimSpace(v); v != "" {
+						addVary(&ctx.Response.Header, v)
+					}
+				}
+			}
+
+			continue
+		}
+
+		for i, value := range values {
+			// Set handles the headers stored apart by fasthttp (eg: Content-Type)
+			if i == 0 {
+				ctx.Response.Header.Set(name, value)
+				continue
+			}
+
+			ctx.Response.Header.Add(name, value)
+		}
+	}
+
+	for _, c := range r.cookies {
+		cookie := fasthttp.AcquireCookie()
+
+		cookie.SetKey(c.Name)
+		cookie.SetValue(c.Value)
+		cookie.SetDomain(c.Domain)
+
+		// fasthttp normalizes an empty path to "/"
+		if c.Path != "" {
+			cookie.SetPath(c.Path)
+		}
+
+		cookie.SetHTTPOnly(c.HttpOnly)
+		cookie.SetSecure(c.Secure)
+		cookie.SetSameSite(cookieSameSite(c.SameSite))
+
+		switch {
+		case c.MaxAge < 0:
+			cookie.SetExpire(fasthttp.CookieExpireDelete)
+		case c.MaxAge > 0:
+			cookie.SetMaxAge(c.MaxAge)
+		case c.Expires.IsZero() == false:
+			cookie.SetExpire(c.Expires)
+		}
+
+		ctx.Response.Header.SetCookie(cookie)
+		fasthttp.ReleaseCookie(cookie)
+	}
+}
+
+// cookieSameSite convert the SameSite mode of net/http to the fasthttp one
+func cookieSameSite(mode http.SameSite) fasthttp.CookieSameSite {
+	switch mode {
+	case http.SameSiteDefaultMode:
+		return fasthttp.CookieSameSiteDefaultMode
+	case http.SameSiteLaxMode:
+		return fasthttp.CookieSameSiteLaxMode
+	case http.SameSiteStrictMode:
+		return fasthttp.CookieSameSiteStrictMode
+	case http.SameSiteNoneMode:
+		return fasthttp.CookieSameSiteNoneMode
+	}
+
+	return fasthttp.CookieSameSiteDisabled
+}
+
+// responseHeader return the value of a response header set either on the
+// Response of the request or on the fasthttp response, for the middlewares
+// running before the Response is applied
+func responseHeader(req nanux.Request, httpCtx *fasthttp.RequestCtx, name string) string {
+	if resp, ok := req.M["response"].(*Response); ok == true {
+		if value := resp.header.Get(name); value != "" {
+			return value
+		}
+	}
+
+	return string(httpCtx.Response.Header.Peek(name))
+}
+
+// RequestConfig describes the request built by NewRequest
+type RequestConfig struct {
+	// Method of the request. Default to GET.
+	Method string
+	// URI of the request, with its query string (eg: /orders?limit=10).
+	// Default to /.
+	URI    string
+	Header http.Header
+	Body   []byte
+	// Route of the handler, with its parameters (eg: /orders/:id). Default to
+	// the path of the URI.
+	Route      string
+	PathParams map[string]string
+	// Codecs of the transporter. Default to the JSON codec.
+	Codecs []Codec
+}
+
+// NewRequest return a nanux request as the transporter provides it to the
+// handlers, so that they can be unit tested without running a server. The
+// response of the handler can then be checked with GetResponse.
+func NewRequest(cfg RequestConfig) nanux.Request {
+	if cfg.Method == "" {
+		cfg.Method = fasthttp.MethodGet
+	}
+
+	if cfg.URI == "" {
+		cfg.URI = "/"
+	}
+
+	if len(cfg.Codecs) == 0 {
+		cfg.Codecs = []Codec{JSONCodec{}}
+	}
+
+	var r fasthttp.Request
+	r.Header.SetMethod(cfg.Method)
+	r.SetRequestURI(cfg.URI)
+
+	if cfg.Route == "" {
+		cfg.Route = string(r.URI().Path())
+	}
+	r.SetBody(cfg.Body)
+
+	for name, values := range cfg.Header {
+		for i, value := range values {
+			// Set handles the headers stored apart by fasthttp (eg: Content-Type)
+			if i == 0 {
+				r.Header.Set(name, value)
+				continue
+			}
+
+			r.Header.Add(name, value)
+		}
+	}
+
+	httpCtx := &fasthttp.RequestCtx{}
+	httpCtx.Init(&r, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, nil)
+
+	logger := zerolog.Nop()
+
+	return nanux.Request{
+		Data: cfg.Body,
+		M: map[string]interface{}{
+			"httpCtx":     httpCtx,
+			"logger":      &logger,
+			"context":     context.Background(),
+			"handlerOpts": nanux.HandlerOpts{},
+			"client":      connectionClient(httpCtx),
+			"route":       cfg.Route,
+			"pathParams":  cfg.PathParams,
+			"codecs":      cfg.Codecs,
+			"response":    NewResponse(),
+		},
+	}
+}
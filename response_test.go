@@ -0,0 +1,167 @@
+package thttp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestResponse_apply(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		set            func(resp *Response)
+		wantStatusCode int
+		wantHeaders    map[string]string
+		wantCookies    map[string][]string
+	}{
+		{
+			name:           "nothing set",
+			set:            func(resp *Response) {},
+			wantStatusCode: 200,
+		},
+		{
+			name: "status and headers",
+			set: func(resp *Response) {
+				resp.Redirect("/orders/42", 201)
+				resp.Header().Set("Content-Type", "application/xml")
+				resp.Header().Add("X-Tag", "a")
+				resp.Header().Add("X-Tag", "b")
+			},
+			wantStatusCode: 201,
+			wantHeaders:    map[string]string{"Location": "/orders/42", "Content-Type": "application/xml", "X-Tag": "a", "Vary": "Accept-Encoding"},
+		},
+		{
+			name: "vary merged with the one already set",
+			set: func(resp *Response) {
+				resp.Header().Set("Vary", "Cookie, accept-encoding")
+				resp.Header().Add("Vary", "Accept-Language")
+			},
+			wantStatusCode: 200,
+			wantHeaders:    map[string]string{"Vary": "Accept-Encoding, Cookie, Accept-Language"},
+		},
+		{
+			name: "cookies",
+			set: func(resp *Response) {
+				resp.SetCookie(&http.Cookie{Name: "theme", Value: "dark", Path: "/", HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode, MaxAge: 60})
+				resp.SetCookie(&http.Cookie{Name: "lang", Value: "fr", Expires: expires})
+				resp.SetCookie(&http.Cookie{Name: "old", MaxAge: -1})
+			},
+			wantStatusCode: 200,
+			wantCookies: map[string][]string{
+				"theme": {"theme=dark", "max-age=60", "path=/", "HttpOnly", "secure", "SameSite=Strict"},
+				"lang":  {"lang=fr", "expires=Wed, 02 Jan 2030 03:04:05 GMT"},
+				"old":   {"old=", "expires=Tue, 10 Nov 2009 23:00:00 GMT"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Response.Header.Set("Vary", "Accept-Encoding")
+			resp := NewResponse()
+			tt.set(resp)
+
+			resp.apply(httpCtx)
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("Response.apply() status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			for name, want := range tt.wantHeaders {
+				if got := string(httpCtx.Response.Header.Peek(name)); got != want {
+					t.Errorf("Response.apply() header %s = %s, want %s", name, got, want)
+				}
+			}
+
+			for name, wantParts := range tt.wantCookies {
+				c, ok := responseCookie(httpCtx, name)
+
+				if ok == false {
+					t.Fatalf("Response.apply() cookie %s not set", name)
+				}
+
+				cookie := c.String()
+
+				for _, part := range wantParts {
+					if strings.Contains(cookie, part) == false {
+						t.Errorf("Response.apply() cookie %s = %s, want it to contain %s", name, cookie, part)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	handler := func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+		limit, err := QueryAs(req, "limit", 10)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var body struct {
+			Name string `json:"name"`
+		}
+
+		if err := Decode(req, &body); err != nil {
+			return nil, err
+		}
+
+		resp := GetResponse(req)
+		resp.Redirect("/orders/"+Param(req, "id"), 201)
+		resp.Header().Set("X-Request-ID", Header(req, "X-Request-ID"))
+		GetLogger(req).Info().Int("limit", limit).Msg("order created")
+
+		return Encode(req, map[string]interface{}{"name": body.Name, "limit": limit, "ip": GetClient(req).IP.String()})
+	}
+
+	req := NewRequest(RequestConfig{
+		Method:     "POST",
+		URI:        "/orders/42?limit=5",
+		Header:     http.Header{"Content-Type": {"application/json"}, "X-Request-Id": {"abc"}},
+		Body:       []byte(`{"name":"book"}`),
+		PathParams: map[string]string{"id": "42"},
+	})
+
+	body, err := handler(nil, req)
+
+	if err != nil {
+		t.Fatalf("handler err = %v", err)
+	}
+
+	if string(body) != `{"ip":"127.0.0.1","limit":5,"name":"book"}` {
+		t.Errorf("handler body = %s", body)
+	}
+
+	resp := GetResponse(req)
+
+	if resp.Status() != 201 || resp.Header().Get("Location") != "/orders/42" || resp.Header().Get("X-Request-ID") != "abc" {
+		t.Errorf("handler response = %v, %v", resp.Status(), resp.Header())
+	}
+
+	if httpCtx, _ := GetHTTPCtx(req); string(httpCtx.Method()) != "POST" {
+		t.Errorf("NewRequest() method = %s, want POST", httpCtx.Method())
+	}
+}
+
+func TestGetResponse(t *testing.T) {
+	req := nanux.Request{M: map[string]interface{}{}}
+
+	resp := GetResponse(req)
+
+	if resp == nil || GetResponse(req) != resp {
+		t.Errorf("GetResponse() must inject the response in the request")
+	}
+
+	if GetResponse(nanux.Request{}) == nil {
+		t.Errorf("GetResponse() must return a response without Request.M")
+	}
+}
@@ -0,0 +1,190 @@
+package thttp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	errUnsupportedContentType = errors.New("Unsupported content type")
+	errHandlerTimeout         = errors.New("Handler timeout")
+)
+
+// routeHandler is the handler associated to a route with its options already
+// extracted from the handler options
+type routeHandler struct {
+	nanux.THandler
+
+	// route is the route of the handler, with its parameters (eg: /orders/:id)
+	route string
+
+	maxBody      int
+	timeout      time.Duration
+	contentTypes []string
+	streamBody   bool
+	authz        authorization
+
+	// fn calls the middlewares of the transporter, checks the authorization
+	// and then calls the handler
+	fn func(req nanux.Request) ([]byte, error)
+}
+
+// newRouteHandler extract the options of the handler and wrap it with the
+// middlewares of the transporter. An error is returned if an option is not of
+// the expected type.
+func newRouteHandler(tHandler nanux.THandler, middlewares []nanux.Middleware) (rHandler routeHandler, err error) {
+	var ok bool
+	rHandler.THandler = tHandler
+
+	if maxBodyI, exists := tHandler.Opts[MaxBodyOpt]; exists == true {
+		if rHandler.maxBody, ok = maxBodyI.(int); ok == false {
+			return rHandler, errors.New("Option associated to thttp.MaxBodyOpt is not of type int")
+		}
+	}
+
+	if timeoutI, exists := tHandler.Opts[TimeoutOpt]; exists == true {
+		if rHandler.timeout, ok = timeoutI.(time.Duration); ok == false {
+			return rHandler, errors.New("Option associated to thttp.TimeoutOpt is not of type time.Duration")
+		}
+	}
+
+	if contentTypesI, exists := tHandler.Opts[ContentTypesOpt]; exists == true {
+		if rHandler.contentTypes, ok = contentTypesI.([]string); ok == false {
+			return rHandler, errors.New("Option associated to thttp.ContentTypesOpt is not of type []string")
+		}
+	}
+
+	if streamBodyI, exists := tHandler.Opts[StreamBodyOpt]; exists == true {
+		if rHandler.streamBody, ok = streamBodyI.(bool); ok == false {
+			return rHandler, errors.New("Option associated to thttp.StreamBodyOpt is not of type bool")
+		}
+	}
+
+	if rateLimitI, exists := tHandler.Opts[RateLimitOpt]; exists == true {
+		if _, ok = rateLimitI.(Limit); ok == false {
+			return rHandler, errors.New("Option associated to thttp.RateLimitOpt is not of type thttp.Limit")
+		}
+	}
+
+	if securityHeadersI, exists := tHandler.Opts[SecurityHeadersOpt]; exists == true {
+		if _, ok = securityHeadersI.(map[string]string); ok == false {
+			return rHandler, errors.New("Option associated to thttp.SecurityHeadersOpt is not of type map[string]string")
+		}
+	}
+
+	if csrfExemptI, exists := tHandler.Opts[CSRFExemptOpt]; exists == true {
+		if _, ok = csrfExemptI.(bool); ok == false {
+			return rHandler, errors.New("Option associated to thttp.CSRFExemptOpt is not of type bool")
+		}
+	}
+
+	if rHandler.authz, err = newAuthorization(tHandler.Opts); err != nil {
+		return rHandler, err
+	}
+
+	// the authorization is checked after the middlewares of the transporter so
+	// that they can authenticate the request
+	fn := func(_ *interface{}, req nanux.Request) ([]byte, error) {
+		if statusCode := rHandler.authz.check(req); statusCode != 0 {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if statusCode == fasthttp.StatusUnauthorized {
+				unauthorized(httpCtx, authzChallenge)
+			} else {
+				httpCtx.SetStatusCode(statusCode)
+			}
+
+			return nil, nil
+		}
+
+		return tHandler.Fn(req)
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](fn)
+	}
+
+	rHandler.fn = func(req nanux.Request) ([]byte, error) {
+		return fn(nil, req)
+	}
+
+	return rHandler, nil
+}
+
+// checkRequest enforce the options of the route on the request before the
+// handler is called. The body is decompressed at the same time so that the
+// limits apply to the decompressed body too. The status code to respond with is
+// returned with an error if the request is rejected.
+func (rh routeHandler) checkRequest(ctx *fasthttp.RequestCtx, maxDecompressedSize int) (statusCode int, err error) {
+	if rh.streamBody == true {
+		return rh.checkStream(ctx)
+	}
+
+	if rh.maxBody > 0 {
+		if len(ctx.Request.Body()) > rh.maxBody {
+			return fasthttp.StatusRequestEntityTooLarge, errBodyTooLarge
+		}
+
+		if rh.maxBody < maxDecompressedSize {
+			maxDecompressedSize = rh.maxBody
+		}
+	}
+
+	if statusCode, err = decompressBody(ctx, maxDecompressedSize); err != nil {
+		return statusCode, err
+	}
+
+	if len(rh.contentTypes) > 0 && len(ctx.Request.Body()) > 0 {
+		if matchContentType(string(ctx.Request.Header.ContentType()), rh.contentTypes) == false {
+			return fasthttp.StatusUnsupportedMediaType, errUnsupportedContentType
+		}
+	}
+
+	return 0, nil
+}
+
+// call execute the handler. If the context of the request has a deadline and
+// the handler does not respond before it or the context is cancelled,
+// errHandlerTimeout is returned while the handler keeps running in its own
+// goroutine. release, if not nil, is called once the handler has returned, so
+// that the resources it uses are not released while it is still running.
+func (rh routeHandler) call(ctx context.Context, req nanux.Request, release func()) ([]byte, error) {
+	if release == nil {
+		release = func() {}
+	}
+
+	if _, ok := ctx.Deadline(); ok == false {
+		defer release()
+
+		return rh.fn(req)
+	}
+
+	type result struct {
+		resp []byte
+		err  error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		defer release()
+
+		resp, err := rh.fn(req)
+		done <- result{resp: resp, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.resp, res.err
+	case <-ctx.Done():
+		return nil, errHandlerTimeout
+	}
+}
@@ -0,0 +1,230 @@
+package thttp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestNewRouteHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		opts             nanux.HandlerOpts
+		wantMaxBody      int
+		wantTimeout      time.Duration
+		wantContentTypes []string
+		wantErr          bool
+	}{
+		{
+			name: "no option",
+			opts: nanux.HandlerOpts{},
+		},
+		{
+			name: "all options",
+			opts: nanux.HandlerOpts{
+				MaxBodyOpt:      1024,
+				TimeoutOpt:      time.Second,
+				ContentTypesOpt: []string{"application/json"},
+			},
+			wantMaxBody:      1024,
+			wantTimeout:      time.Second,
+			wantContentTypes: []string{"application/json"},
+		},
+		{
+			name:    "max body wrong type",
+			opts:    nanux.HandlerOpts{MaxBodyOpt: "1024"},
+			wantErr: true,
+		},
+		{
+			name:    "timeout wrong type",
+			opts:    nanux.HandlerOpts{TimeoutOpt: 1000},
+			wantErr: true,
+		},
+		{
+			name:    "content types wrong type",
+			opts:    nanux.HandlerOpts{ContentTypesOpt: "application/json"},
+			wantErr: true,
+		},
+		{
+			name:    "rate limit wrong type",
+			opts:    nanux.HandlerOpts{RateLimitOpt: 10},
+			wantErr: true,
+		},
+		{
+			name:    "security headers wrong type",
+			opts:    nanux.HandlerOpts{SecurityHeadersOpt: "X-Frame-Options: SAMEORIGIN"},
+			wantErr: true,
+		},
+		{
+			name:    "CSRF exemption wrong type",
+			opts:    nanux.HandlerOpts{CSRFExemptOpt: "true"},
+			wantErr: true,
+		},
+		{
+			name:    "stream body wrong type",
+			opts:    nanux.HandlerOpts{StreamBodyOpt: "true"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rHandler, err := newRouteHandler(nanux.THandler{Opts: tt.opts}, nil)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRouteHandler() err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == true {
+				return
+			}
+
+			if rHandler.maxBody != tt.wantMaxBody {
+				t.Errorf("newRouteHandler() maxBody = %v, want %v", rHandler.maxBody, tt.wantMaxBody)
+			}
+
+			if rHandler.timeout != tt.wantTimeout {
+				t.Errorf("newRouteHandler() timeout = %v, want %v", rHandler.timeout, tt.wantTimeout)
+			}
+
+			if reflect.DeepEqual(rHandler.contentTypes, tt.wantContentTypes) == false {
+				t.Errorf("newRouteHandler() contentTypes = %v, want %v", rHandler.contentTypes, tt.wantContentTypes)
+			}
+		})
+	}
+}
+
+func TestRouteHandler_checkRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		rHandler       routeHandler
+		contentType    string
+		body           []byte
+		wantStatusCode int
+	}{
+		{
+			name:     "no option",
+			rHandler: routeHandler{},
+			body:     []byte("body"),
+		},
+		{
+			name:     "body within the limit",
+			rHandler: routeHandler{maxBody: 4},
+			body:     []byte("body"),
+		},
+		{
+			name:           "body too large",
+			rHandler:       routeHandler{maxBody: 3},
+			body:           []byte("body"),
+			wantStatusCode: fasthttp.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "accepted content type",
+			rHandler:    routeHandler{contentTypes: []string{"application/json"}},
+			contentType: "application/json; charset=utf-8",
+			body:        []byte("{}"),
+		},
+		{
+			name:           "unsupported content type",
+			rHandler:       routeHandler{contentTypes: []string{"application/json"}},
+			contentType:    "text/plain",
+			body:           []byte("{}"),
+			wantStatusCode: fasthttp.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "content type not checked without body",
+			rHandler:    routeHandler{contentTypes: []string{"application/json"}},
+			contentType: "text/plain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.SetContentType(tt.contentType)
+			httpCtx.Request.SetBody(tt.body)
+
+			statusCode, err := tt.rHandler.checkRequest(httpCtx, DefaultMaxDecompressedSize)
+
+			if statusCode != tt.wantStatusCode {
+				t.Errorf("routeHandler.checkRequest() statusCode = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			if (err != nil) != (tt.wantStatusCode != 0) {
+				t.Errorf("routeHandler.checkRequest() err = %v", err)
+			}
+		})
+	}
+}
+
+func TestRouteHandler_call(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		duration time.Duration
+		wantResp []byte
+		wantErr  error
+	}{
+		{
+			name:     "no timeout",
+			duration: 10 * time.Millisecond,
+			wantResp: []byte("response"),
+		},
+		{
+			name:     "handler responding in time",
+			timeout:  50 * time.Millisecond,
+			duration: 10 * time.Millisecond,
+			wantResp: []byte("response"),
+		},
+		{
+			name:     "handler too slow",
+			timeout:  10 * time.Millisecond,
+			duration: 50 * time.Millisecond,
+			wantErr:  errHandlerTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duration := tt.duration
+			rHandler := routeHandler{
+				fn: func(nanux.Request) ([]byte, error) {
+					time.Sleep(duration)
+
+					return []byte("response"), nil
+				},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+
+			defer cancel()
+
+			released := make(chan bool, 1)
+			resp, err := rHandler.call(ctx, nanux.Request{}, func() { released <- true })
+
+			if err != tt.wantErr {
+				t.Errorf("routeHandler.call() err = %v, want %v", err, tt.wantErr)
+			}
+
+			if string(resp) != string(tt.wantResp) {
+				t.Errorf("routeHandler.call() resp = %s, want %s", resp, tt.wantResp)
+			}
+
+			// the resources of the handler are released once it has returned,
+			// even after a timeout
+			select {
+			case <-released:
+			case <-time.After(time.Second):
+				t.Errorf("routeHandler.call() must release the resources once the handler returns")
+			}
+		})
+	}
+}
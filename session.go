@@ -0,0 +1,574 @@
+package thttp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// maxSessionCookieSize is the maximum size of a cookie accepted by the browsers
+const maxSessionCookieSize = 4096
+
+var (
+	errSessionCookie   = errors.New("Sessions : invalid session cookie")
+	errSessionTooLarge = errors.New("Sessions : session too large to be stored in a cookie")
+)
+
+// SessionData is the content of a session saved by the stores. The values are
+// encoded in JSON so they must be JSON serializable, and numbers are decoded
+// as float64.
+type SessionData struct {
+	Values    map[string]interface{} `json:"values"`
+	CreatedAt time.Time              `json:"created_at"`
+	LastSeen  time.Time              `json:"last_seen"`
+}
+
+// Session is the session of the client making the request. It is not safe for
+// concurrent use.
+type Session struct {
+	id        string
+	data      SessionData
+	isNew     bool
+	modified  bool
+	rotate    bool
+	destroyed bool
+}
+
+// ID return the identifier of the session, empty for a new session
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew tells if the session has been created by the current request
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// Get return the value associated to the key, nil if there is none
+func (s *Session) Get(key string) interface{} {
+	return s.data.Values[key]
+}
+
+// Set associate the value to the key
+func (s *Session) Set(key string, value interface{}) {
+	s.data.Values[key] = value
+	s.modified = true
+}
+
+// Delete remove the value associated to the key
+func (s *Session) Delete(key string) {
+	delete(s.data.Values, key)
+	s.modified = true
+}
+
+// Rotate gives a new ID to the session when the response is sent and restarts
+// its absolute expiry. It must be called when the privileges of the client
+// change (eg: on login) to prevent session fixation.
+func (s *Session) Rotate() {
+	s.rotate = true
+	s.modified = true
+}
+
+// Destroy remove the session when the response is sent (eg: on logout)
+func (s *Session) Destroy() {
+	s.destroyed = true
+}
+
+// GetSession return the session injected in the nanux request by the Sessions
+// middleware. false is returned if there is none.
+func GetSession(req nanux.Request) (*Session, bool) {
+	session, ok := req.M["session"].(*Session)
+
+	return session, ok
+}
+
+// SessionStore saves the sessions on the server side
+type SessionStore interface {
+	// Load return the session associated to the id. false is returned if the
+	// session does not exist or has expired.
+	Load(id string) (SessionData, bool, error)
+	// Save the session until its expiry date
+	Save(id string, data SessionData, expiresAt time.Time) error
+	// Delete the session
+	Delete(id string) error
+}
+
+// SessionConfig define the configuration of the Sessions middleware
+type SessionConfig struct {
+	// Store saves the sessions on the server side, only their ID is sent in
+	// the cookie. The sessions are saved in the cookie if it is nil.
+	Store SessionStore
+	// Secret signs the cookies. It is required when the sessions are saved in
+	// the cookie.
+	Secret []byte
+	// EncryptionKey encrypts the cookies with AES-GCM if it is set. It must be
+	// 16, 24 or 32 bytes long.
+	EncryptionKey []byte
+
+	// CookieName is the name of the session cookie. Default to "session".
+	CookieName string
+	// Path of the cookie. Default to "/".
+	Path string
+	// Domain of the cookie
+	Domain string
+	// Secure tells the browsers to only send the cookie over https
+	Secure bool
+	// SameSite attribute of the cookie. Default to lax.
+	SameSite fasthttp.CookieSameSite
+
+	// IdleTimeout is the duration after which a session which has not been
+	// used expires. Default to 30 minutes.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout is the duration after which a session expires even if it
+	// is used. Default to 24 hours.
+	AbsoluteTimeout time.Duration
+}
+
+// Sessions return a middleware injecting the session of the client in
+// `req.M["session"]`. The session is saved when the handler returns without
+// error. A cookie is only sent to the clients whose session has been modified,
+// so that no session is created for anonymous visitors.
+func Sessions(cfg SessionConfig) (nanux.Middleware, error) {
+	if cfg.CookieName == "" {
+		cfg.CookieName = "session"
+	}
+
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+
+	if cfg.SameSite == fasthttp.CookieSameSiteDisabled {
+		cfg.SameSite = fasthttp.CookieSameSiteLaxMode
+	}
+
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 30 * time.Minute
+	}
+
+	if cfg.AbsoluteTimeout == 0 {
+		cfg.AbsoluteTimeout = 24 * time.Hour
+	}
+
+	m := &sessionManager{cfg: cfg}
+
+	if cfg.Store == nil {
+		codec, err := newCookieCodec(cfg.Secret, cfg.EncryptionKey)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.codec = codec
+	}
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			now := time.Now()
+			session, err := m.load(httpCtx, now)
+
+			if err != nil {
+				return nil, err
+			}
+
+			req.M["session"] = session
+
+			resp, err := fn(ctx, req)
+
+			if err != nil {
+				return resp, err
+			}
+
+			if err := m.save(httpCtx, session, now); err != nil {
+				return nil, err
+			}
+
+			return resp, nil
+		}
+	}, nil
+}
+
+// sessionManager loads and saves the sessions in the store or in the cookie
+type sessionManager struct {
+	cfg   SessionConfig
+	codec *cookieCodec
+}
+
+// cookieSession is the content of the cookie when the sessions are saved in
+// the cookie
+type cookieSession struct {
+	ID string `json:"id"`
+	SessionData
+}
+
+// load the session of the request. A new session is returned if there is
+// none or if it has expired.
+func (m *sessionManager) load(httpCtx *fasthttp.RequestCtx, now time.Time) (*Session, error) {
+	session := &Session{
+		data:  SessionData{Values: make(map[string]interface{}), CreatedAt: now},
+		isNew: true,
+	}
+
+	value := string(httpCtx.Request.Header.Cookie(m.cfg.CookieName))
+
+	if value == "" {
+		return session, nil
+	}
+
+	var id string
+	var data SessionData
+	var ok bool
+
+	if m.cfg.Store != nil {
+		var err error
+		id = value
+
+		if data, ok, err = m.cfg.Store.Load(id); err != nil {
+			return nil, err
+		}
+	} else {
+		var cs cookieSession
+
+		if payload, err := m.codec.decode(value); err == nil && json.Unmarshal(payload, &cs) == nil {
+			id, data, ok = cs.ID, cs.SessionData, true
+		}
+	}
+
+	if ok == false {
+		return session, nil
+	}
+
+	if m.expiresAt(data).After(now) == false {
+		if m.cfg.Store != nil {
+			return session, m.cfg.Store.Delete(id)
+		}
+
+		return session, nil
+	}
+
+	if data.Values == nil {
+		data.Values = make(map[string]interface{})
+	}
+
+	return &Session{id: id, data: data}, nil
+}
+
+// save the session and send its cookie
+func (m *sessionManager) save(httpCtx *fasthttp.RequestCtx, session *Session, now time.Time) error {
+	if session.destroyed == true {
+		if m.cfg.Store != nil && session.id != "" {
+			if err := m.cfg.Store.Delete(session.id); err != nil {
+				return err
+			}
+		}
+
+		m.setCookie(httpCtx, "", fasthttp.CookieExpireDelete)
+
+		return nil
+	}
+
+	if session.isNew == true && session.modified == false {
+		return nil
+	}
+
+	if session.id == "" || session.rotate == true {
+		oldID := session.id
+		id, err := newSessionID()
+
+		if err != nil {
+			return err
+		}
+
+		session.id = id
+
+		if oldID != "" {
+			session.data.CreatedAt = now
+
+			if m.cfg.Store != nil {
+				if err := m.cfg.Store.Delete(oldID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	session.data.LastSeen = now
+	expiresAt := m.expiresAt(session.data)
+
+	if m.cfg.Store != nil {
+		if err := m.cfg.Store.Save(session.id, session.data, expiresAt); err != nil {
+			return err
+		}
+
+		m.setCookie(httpCtx, session.id, expiresAt)
+
+		return nil
+	}
+
+	payload, err := json.Marshal(cookieSession{ID: session.id, SessionData: session.data})
+
+	if err != nil {
+		return err
+	}
+
+	value, err := m.codec.encode(payload)
+
+	if err != nil {
+		return err
+	}
+
+	if len(value) > maxSessionCookieSize {
+		return errSessionTooLarge
+	}
+
+	m.setCookie(httpCtx, value, expiresAt)
+
+	return nil
+}
+
+// expiresAt return the date at which the session expires
+func (m *sessionManager) expiresAt(data SessionData) time.Time {
+	idle := data.LastSeen.Add(m.cfg.IdleTimeout)
+	absolute := data.CreatedAt.Add(m.cfg.AbsoluteTimeout)
+
+	if idle.Before(absolute) == true {
+		return idle
+	}
+
+	return absolute
+}
+
+// setCookie set the session cookie of the response
+func (m *sessionManager) setCookie(httpCtx *fasthttp.RequestCtx, value string, expire time.Time) {
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+
+	cookie.SetKey(m.cfg.CookieName)
+	cookie.SetValue(value)
+	cookie.SetPath(m.cfg.Path)
+	cookie.SetDomain(m.cfg.Domain)
+	cookie.SetExpire(expire)
+	cookie.SetHTTPOnly(true)
+	cookie.SetSecure(m.cfg.Secure)
+	cookie.SetSameSite(m.cfg.SameSite)
+
+	httpCtx.Response.Header.SetCookie(cookie)
+}
+
+// newSessionID return a random session ID
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// cookieCodec signs and optionally encrypts the content of the cookies
+type cookieCodec struct {
+	secret []byte
+	aead   cipher.AEAD
+}
+
+// newCookieCodec return a codec signing with the secret and encrypting with
+// the key if it is set
+func newCookieCodec(secret, key []byte) (*cookieCodec, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("Sessions : a secret is required to sign the session cookies")
+	}
+
+	c := &cookieCodec{secret: secret}
+
+	if len(key) > 0 {
+		block, err := aes.NewCipher(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if c.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// encode return the value of the cookie: the payload, encrypted if the codec
+// has a key, and its signature
+func (c *cookieCodec) encode(payload []byte) (string, error) {
+	if c.aead != nil {
+		nonce := make([]byte, c.aead.NonceSize())
+
+		// a nonce must never be reused with the same key
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+
+		payload = c.aead.Seal(nonce, nonce, payload, nil)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(encoded)), nil
+}
+
+// decode check the signature of the cookie and return its payload
+func (c *cookieCodec) decode(value string) ([]byte, error) {
+	i := strings.LastIndexByte(value, '.')
+
+	if i < 0 {
+		return nil, errSessionCookie
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+
+	if err != nil || hmac.Equal(signature, c.sign(value[:i])) == false {
+		return nil, errSessionCookie
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(value[:i])
+
+	if err != nil {
+		return nil, errSessionCookie
+	}
+
+	if c.aead == nil {
+		return payload, nil
+	}
+
+	if len(payload) < c.aead.NonceSize() {
+		return nil, errSessionCookie
+	}
+
+	nonce := payload[:c.aead.NonceSize()]
+
+	if payload, err = c.aead.Open(nil, nonce, payload[len(nonce):], nil); err != nil {
+		return nil, errSessionCookie
+	}
+
+	return payload, nil
+}
+
+// sign return the HMAC of the value
+func (c *cookieCodec) sign(value string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(value))
+
+	return mac.Sum(nil)
+}
+
+// storedSession is a session saved by a store with its expiry date
+type storedSession struct {
+	Data      json.RawMessage `json:"data"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// load decode the session if it has not expired
+func (s storedSession) load(now time.Time) (SessionData, bool, error) {
+	var data SessionData
+
+	if now.Before(s.ExpiresAt) == false {
+		return data, false, nil
+	}
+
+	if err := json.Unmarshal(s.Data, &data); err != nil {
+		return data, false, err
+	}
+
+	return data, true, nil
+}
+
+// MemorySessionStore holds the sessions in memory. They are lost when the
+// service restarts and are not shared between its instances.
+type MemorySessionStore struct {
+	mu              sync.Mutex
+	sessions        map[string]storedSession
+	cleanupInterval time.Duration
+	lastCleanup     time.Time
+}
+
+// NewMemorySessionStore return a store removing the expired sessions at the
+// specified interval
+func NewMemorySessionStore(cleanupInterval time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions:        make(map[string]storedSession),
+		cleanupInterval: cleanupInterval,
+		lastCleanup:     time.Now(),
+	}
+}
+
+// Load return the session associated to the id
+func (m *MemorySessionStore) Load(id string) (SessionData, bool, error) {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	m.mu.Unlock()
+
+	if ok == false {
+		return SessionData{}, false, nil
+	}
+
+	return s.load(time.Now())
+}
+
+// Save the session. It is encoded so that the requests of a client do not
+// share its values.
+func (m *MemorySessionStore) Save(id string, data SessionData, expiresAt time.Time) error {
+	encoded, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastCleanup) >= m.cleanupInterval {
+		m.cleanup(now)
+	}
+
+	m.sessions[id] = storedSession{Data: encoded, ExpiresAt: expiresAt}
+
+	return nil
+}
+
+// Delete the session
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// cleanup remove the expired sessions
+func (m *MemorySessionStore) cleanup(now time.Time) {
+	for id, s := range m.sessions {
+		if now.Before(s.ExpiresAt) == false {
+			delete(m.sessions, id)
+		}
+	}
+
+	m.lastCleanup = now
+}
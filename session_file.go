@@ -0,0 +1,163 @@
+package thttp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var errSessionID = errors.New("FileSessionStore : invalid session ID")
+
+// FileSessionStore saves each session in a file of a directory. The expired
+// sessions are removed when they are loaded or by Cleanup.
+type FileSessionStore struct {
+	dir string
+}
+
+// NewFileSessionStore return a store saving the sessions in the directory,
+// which is created if it does not exist
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &FileSessionStore{dir: dir}, nil
+}
+
+// Load return the session associated to the id
+func (f *FileSessionStore) Load(id string) (SessionData, bool, error) {
+	path, err := f.path(id)
+
+	if err != nil {
+		// the ID comes from the client so an invalid one is an unknown session
+		return SessionData{}, false, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) == true {
+		return SessionData{}, false, nil
+	}
+
+	if err != nil {
+		return SessionData{}, false, err
+	}
+
+	var s storedSession
+
+	if err := json.Unmarshal(content, &s); err != nil {
+		return SessionData{}, false, err
+	}
+
+	data, ok, err := s.load(time.Now())
+
+	if err == nil && ok == false {
+		err = f.Delete(id)
+	}
+
+	return data, ok, err
+}
+
+// Save the session. The file is written atomically so that a concurrent Load
+// never reads a partial session.
+func (f *FileSessionStore) Save(id string, data SessionData, expiresAt time.Time) error {
+	path, err := f.path(id)
+
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(storedSession{Data: encoded, ExpiresAt: expiresAt})
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(f.dir, ".tmp-")
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete the session
+func (f *FileSessionStore) Delete(id string) error {
+	path, err := f.path(id)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && os.IsNotExist(err) == false {
+		return err
+	}
+
+	return nil
+}
+
+// Cleanup remove the files of the expired sessions. It should be called
+// periodically.
+func (f *FileSessionStore) Cleanup() error {
+	files, err := ioutil.ReadDir(f.dir)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, file := range files {
+		if _, err := f.path(file.Name()); err != nil {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(f.dir, file.Name()))
+
+		if err != nil {
+			continue
+		}
+
+		var s storedSession
+
+		if json.Unmarshal(content, &s) != nil || now.Before(s.ExpiresAt) == false {
+			os.Remove(filepath.Join(f.dir, file.Name()))
+		}
+	}
+
+	return nil
+}
+
+// path return the path of the session file. The ID must be hexadecimal so
+// that it can not point outside of the directory.
+func (f *FileSessionStore) path(id string) (string, error) {
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return "", errSessionID
+	}
+
+	return filepath.Join(f.dir, id), nil
+}
@@ -0,0 +1,347 @@
+package thttp
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// responseCookie return the cookie set in the response
+func responseCookie(httpCtx *fasthttp.RequestCtx, name string) (*fasthttp.Cookie, bool) {
+	cookie := &fasthttp.Cookie{}
+	cookie.SetKey(name)
+
+	return cookie, httpCtx.Response.Header.Cookie(cookie)
+}
+
+func TestSessions(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	configs := map[string]SessionConfig{
+		"signed cookie":    {Secret: secret},
+		"encrypted cookie": {Secret: secret, EncryptionKey: secret},
+		"memory store":     {Store: NewMemorySessionStore(time.Minute)},
+	}
+
+	for name, cfg := range configs {
+		cfg := cfg
+
+		t.Run(name, func(t *testing.T) {
+			sessions, err := Sessions(cfg)
+
+			if err != nil {
+				t.Fatalf("Sessions() err = %v", err)
+			}
+
+			// call the handler with the session cookie and return the cookie of
+			// the response
+			call := func(cookie string, handler func(*Session)) (string, bool) {
+				httpCtx := &fasthttp.RequestCtx{}
+
+				if cookie != "" {
+					httpCtx.Request.Header.SetCookie("session", cookie)
+				}
+
+				req := nanux.Request{M: map[string]interface{}{"httpCtx": httpCtx}}
+
+				_, err := sessions(func(_ *interface{}, req nanux.Request) ([]byte, error) {
+					session, ok := GetSession(req)
+
+					if ok == false {
+						t.Fatalf("GetSession() must return the session")
+					}
+
+					handler(session)
+
+					return nil, nil
+				})(nil, req)
+
+				if err != nil {
+					t.Fatalf("Sessions() - error occured when calling handler - %s", err)
+				}
+
+				c, ok := responseCookie(httpCtx, "session")
+
+				if ok == true && c.HTTPOnly() == false {
+					t.Errorf("Sessions() - cookie must be http only")
+				}
+
+				return string(c.Value()), ok
+			}
+
+			if _, ok := call("", func(*Session) {}); ok == true {
+				t.Errorf("Sessions() - cookie must not be sent for an unmodified new session")
+			}
+
+			cookie, ok := call("", func(s *Session) {
+				if s.IsNew() == false {
+					t.Errorf("Session.IsNew() = false, want true")
+				}
+
+				s.Set("user", "alice")
+			})
+
+			if ok == false || cookie == "" {
+				t.Fatalf("Sessions() - cookie must be sent for a modified session")
+			}
+
+			var id string
+
+			cookie, _ = call(cookie, func(s *Session) {
+				id = s.ID()
+
+				if s.IsNew() == true || s.Get("user") != "alice" {
+					t.Errorf("Sessions() - session not loaded, values = %v", s.data.Values)
+				}
+			})
+
+			if _, ok := call("tampered"+cookie, func(s *Session) {
+				if s.IsNew() == false {
+					t.Errorf("Sessions() - tampered cookie must not be loaded")
+				}
+			}); ok == true {
+				t.Errorf("Sessions() - cookie must not be sent for a tampered cookie")
+			}
+
+			cookie, _ = call(cookie, func(s *Session) {
+				s.Rotate()
+			})
+
+			call(cookie, func(s *Session) {
+				if s.ID() == id || s.Get("user") != "alice" {
+					t.Errorf("Sessions() - rotated session ID = %v, values = %v", s.ID(), s.data.Values)
+				}
+			})
+
+			if cfg.Store != nil {
+				if _, ok, _ := cfg.Store.Load(id); ok == true {
+					t.Errorf("Sessions() - session must be removed from the store after a rotation")
+				}
+			}
+
+			cookie, ok = call(cookie, func(s *Session) {
+				s.Destroy()
+			})
+
+			if ok == false || cookie != "" {
+				t.Errorf("Sessions() - cookie must be removed when the session is destroyed")
+			}
+		})
+	}
+}
+
+func TestSessions_config(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SessionConfig
+		wantErr bool
+	}{
+		{name: "cookie without secret", cfg: SessionConfig{}, wantErr: true},
+		{name: "invalid encryption key", cfg: SessionConfig{Secret: []byte("secret"), EncryptionKey: []byte("short")}, wantErr: true},
+		{name: "store without secret", cfg: SessionConfig{Store: NewMemorySessionStore(time.Minute)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Sessions(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Sessions() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionManager_expiry(t *testing.T) {
+	store := NewMemorySessionStore(time.Minute)
+	m := &sessionManager{cfg: SessionConfig{
+		Store:           store,
+		CookieName:      "session",
+		IdleTimeout:     time.Hour,
+		AbsoluteTimeout: 3 * time.Hour,
+	}}
+
+	start := time.Now()
+
+	// save a session at the specified time, with the cookie of the previous
+	// request
+	save := func(cookie string, now time.Time) (*Session, string) {
+		httpCtx := &fasthttp.RequestCtx{}
+		httpCtx.Request.Header.SetCookie("session", cookie)
+
+		session, err := m.load(httpCtx, now)
+
+		if err != nil {
+			t.Fatalf("sessionManager.load() err = %v", err)
+		}
+
+		session.Set("user", "alice")
+
+		if err := m.save(httpCtx, session, now); err != nil {
+			t.Fatalf("sessionManager.save() err = %v", err)
+		}
+
+		c, _ := responseCookie(httpCtx, "session")
+
+		return session, string(c.Value())
+	}
+
+	_, cookie := save("", start)
+
+	// the session is used every 50 minutes so it does not reach the idle
+	// timeout, until it reaches the absolute timeout
+	for i := 1; i <= 4; i++ {
+		session, _ := save(cookie, start.Add(time.Duration(i)*50*time.Minute))
+
+		if session.IsNew() != (i == 4) {
+			t.Errorf("session IsNew() after %d minutes = %v", i*50, session.IsNew())
+		}
+	}
+
+	_, cookie = save("", start)
+	session, _ := save(cookie, start.Add(61*time.Minute))
+
+	if session.IsNew() == false {
+		t.Errorf("session must expire after the idle timeout")
+	}
+}
+
+func TestCookieCodec(t *testing.T) {
+	signed, _ := newCookieCodec([]byte("secret"), nil)
+	encrypted, _ := newCookieCodec([]byte("secret"), []byte("0123456789abcdef"))
+	otherSecret, _ := newCookieCodec([]byte("other"), nil)
+	payload := []byte(`{"user":"alice"}`)
+
+	signedValue, err := signed.encode(payload)
+
+	if err != nil {
+		t.Fatalf("cookieCodec.encode() err = %v", err)
+	}
+
+	encryptedValue, err := encrypted.encode(payload)
+
+	if err != nil {
+		t.Fatalf("cookieCodec.encode() err = %v", err)
+	}
+
+	if strings.Contains(encryptedValue, "YWxpY2") == true {
+		t.Errorf("cookieCodec.encode() must encrypt the payload")
+	}
+
+	tests := []struct {
+		name    string
+		codec   *cookieCodec
+		value   string
+		wantErr bool
+	}{
+		{name: "signed", codec: signed, value: signedValue},
+		{name: "encrypted", codec: encrypted, value: encryptedValue},
+		{name: "other secret", codec: otherSecret, value: signedValue, wantErr: true},
+		{name: "not encrypted", codec: encrypted, value: signedValue, wantErr: true},
+		{name: "tampered payload", codec: signed, value: "x" + signedValue, wantErr: true},
+		{name: "missing signature", codec: signed, value: strings.Split(signedValue, ".")[0], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.codec.decode(tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cookieCodec.decode() err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == false && string(got) != string(payload) {
+				t.Errorf("cookieCodec.decode() = %s, want %s", got, payload)
+			}
+		})
+	}
+}
+
+func TestSessionStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thttp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fileStore, err := NewFileSessionStore(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]SessionStore{
+		"memory": NewMemorySessionStore(time.Minute),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		store := store
+
+		t.Run(name, func(t *testing.T) {
+			data := SessionData{Values: map[string]interface{}{"user": "alice", "visits": 2}}
+
+			if err := store.Save("abcd", data, time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("Save() err = %v", err)
+			}
+
+			if err := store.Save("ef01", data, time.Now().Add(-time.Second)); err != nil {
+				t.Fatalf("Save() err = %v", err)
+			}
+
+			got, ok, err := store.Load("abcd")
+
+			if err != nil || ok == false || got.Values["user"] != "alice" || got.Values["visits"] != float64(2) {
+				t.Errorf("Load() = %v, %v, %v", got, ok, err)
+			}
+
+			if _, ok, err := store.Load("ef01"); err != nil || ok == true {
+				t.Errorf("Load() of an expired session = %v, %v", ok, err)
+			}
+
+			if _, ok, err := store.Load("0000"); err != nil || ok == true {
+				t.Errorf("Load() of an unknown session = %v, %v", ok, err)
+			}
+
+			if err := store.Delete("abcd"); err != nil {
+				t.Fatalf("Delete() err = %v", err)
+			}
+
+			if _, ok, _ := store.Load("abcd"); ok == true {
+				t.Errorf("Load() must not find a deleted session")
+			}
+		})
+	}
+
+	t.Run("file store rejects invalid ids", func(t *testing.T) {
+		if _, ok, err := fileStore.Load("../passwd"); err != nil || ok == true {
+			t.Errorf("Load() = %v, %v", ok, err)
+		}
+
+		if err := fileStore.Save("../passwd", SessionData{}, time.Now().Add(time.Hour)); err == nil {
+			t.Errorf("Save() must fail with an invalid id")
+		}
+	})
+
+	t.Run("file store cleanup", func(t *testing.T) {
+		fileStore.Save("aaaa", SessionData{}, time.Now().Add(-time.Second))
+		fileStore.Save("bbbb", SessionData{}, time.Now().Add(time.Hour))
+
+		if err := fileStore.Cleanup(); err != nil {
+			t.Fatalf("Cleanup() err = %v", err)
+		}
+
+		if _, err := os.Stat(dir + "/aaaa"); os.IsNotExist(err) == false {
+			t.Errorf("Cleanup() must remove the expired sessions")
+		}
+
+		if _, err := os.Stat(dir + "/bbbb"); err != nil {
+			t.Errorf("Cleanup() must keep the valid sessions, err = %v", err)
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package thttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// errStreamTooLarge is returned by the body stream of a route when it exceeds
+// the MaxBodyOpt of the route. Handlers returning it answer with a 413 status
+// code.
+var errStreamTooLarge = &Error{Status: 413, Message: "Request body too large"}
+
+// BodyStream return the reader of the request body. For the routes streaming
+// their body (see StreamBodyOpt) the body is read from the connection while the
+// reader is read, once decompressed and within the MaxBodyOpt of the route: a
+// reader exceeding it returns an error with a 413 status code. For the other
+// routes it reads req.Data.
+func BodyStream(req nanux.Request) io.Reader {
+	if stream, ok := req.M["bodyStream"].(io.Reader); ok == true {
+		return stream
+	}
+
+	return bytes.NewReader(req.Data)
+}
+
+// enableStreaming configure the server to stream the request bodies. fasthttp
+// then only reads in memory the bodies which fit in its MaxRequestBodySize and
+// does not reject the bigger ones, so the routes which do not stream their
+// body read it with readBody. The multipart forms are not parsed in advance
+// since their files would be buffered.
+func (t *Transporter) enableStreaming() {
+	t.Server.StreamRequestBody = true
+	t.Server.DisablePreParseMultipartForm = true
+}
+
+// readBody read in memory the body streamed by fasthttp for a route which does
+// not stream it. The MaxRequestBodySize of the server is enforced since fasthttp
+// does not enforce it when streaming. The status code to respond with is
+// returned if the body can not be read.
+func (t *Transporter) readBody(ctx *fasthttp.RequestCtx) (statusCode int, err error) {
+	if ctx.Request.IsBodyStream() == false {
+		return 0, nil
+	}
+
+	maxSize := t.Server.MaxRequestBodySize
+
+	if maxSize <= 0 {
+		maxSize = fasthttp.DefaultMaxRequestBodySize
+	}
+
+	if ctx.Request.Header.ContentLength() > maxSize {
+		return fasthttp.StatusRequestEntityTooLarge, errBodyTooLarge
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(ctx.RequestBodyStream(), int64(maxSize)+1))
+
+	if err != nil {
+		return fasthttp.StatusBadRequest, err
+	}
+
+	if len(body) > maxSize {
+		return fasthttp.StatusRequestEntityTooLarge, errBodyTooLarge
+	}
+
+	ctx.Request.SetBody(body)
+
+	return 0, nil
+}
+
+// checkStream enforce the options of a route streaming its body from the
+// headers of the request, since its body has not been read yet
+func (rh routeHandler) checkStream(ctx *fasthttp.RequestCtx) (statusCode int, err error) {
+	contentLength := ctx.Request.Header.ContentLength()
+
+	if rh.maxBody > 0 && contentLength > rh.maxBody {
+		return fasthttp.StatusRequestEntityTooLarge, errBodyTooLarge
+	}
+
+	for _, encoding := range strings.Split(string(ctx.Request.Header.Peek("Content-Encoding")), ",") {
+		switch strings.ToLower(strings.TrimSpace(encoding)) {
+		case "", "identity", EncodingGzip, "x-gzip", EncodingDeflate, EncodingBrotli, EncodingZstd:
+		default:
+			ctx.Response.Header.Set("Accept-Encoding", "gzip, br, zstd, deflate")
+			return fasthttp.StatusUnsupportedMediaType, errUnsupportedEncoding
+		}
+	}
+
+	// a negative content length means the body is chunked
+	if len(rh.contentTypes) > 0 && contentLength != 0 {
+		if matchContentType(string(ctx.Request.Header.ContentType()), rh.contentTypes) == false {
+			return fasthttp.StatusUnsupportedMediaType, errUnsupportedContentType
+		}
+	}
+
+	return 0, nil
+}
+
+// streamedBody is the body of a route streaming it
+type streamedBody struct {
+	// Reader is the decoded body, within the limits of the route
+	io.Reader
+
+	// conn reads the body from the connection, it is nil when fasthttp has
+	// already read the body
+	conn    *detachableReader
+	closers []io.Closer
+}
+
+// detach the body from the connection of the request, so that the handler can
+// keep running once the fasthttp handler has returned: fasthttp then reuses the
+// reader of the connection. The read in progress is interrupted.
+func (b *streamedBody) detach(ctx *fasthttp.RequestCtx) {
+	if b.conn == nil {
+		return
+	}
+
+	if conn := ctx.Conn(); conn != nil {
+		conn.SetReadDeadline(time.Now())
+	}
+
+	b.conn.detach()
+}
+
+// close release the decoders of the body. It must only be called once the
+// handler has returned.
+func (b *streamedBody) close() {
+	for _, closer := range b.closers {
+		closer.Close()
+	}
+}
+
+// bodyStream return the body of a route streaming it, decoding its
+// Content-Encoding and enforcing the MaxBodyOpt of the route. A compressed body
+// is also limited to maxDecompressedSize once decoded.
+func (rh routeHandler) bodyStream(ctx *fasthttp.RequestCtx, maxDecompressedSize int) *streamedBody {
+	var r io.Reader = bytes.NewReader(nil)
+	body := &streamedBody{}
+
+	if ctx.Request.IsBodyStream() == true {
+		body.conn = &detachableReader{r: ctx.RequestBodyStream()}
+		r = body.conn
+	} else if data := ctx.Request.Body(); len(data) > 0 {
+		r = bytes.NewReader(data)
+	}
+
+	encodings := strings.Split(string(ctx.Request.Header.Peek("Content-Encoding")), ",")
+
+	// encodings are listed in the order they were applied so they are decoded
+	// from the last one to the first one
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+
+		if encoding == "" {
+			continue
+		}
+
+		decoder, err := newDecoder(encoding, r)
+
+		if err != nil {
+			r = &errReader{err: &Error{Status: 400, Message: "Invalid " + encoding + " body : " + err.Error()}}
+			break
+		}
+
+		body.closers = append(body.closers, decoder)
+		r = decoder
+	}
+
+	maxBody := rh.maxBody
+
+	// the decompressed bodies are limited as when they are read in memory, so
+	// that a small compressed body can not expand without limit
+	if len(body.closers) > 0 && maxDecompressedSize > 0 && (maxBody <= 0 || maxDecompressedSize < maxBody) {
+		maxBody = maxDecompressedSize
+	}
+
+	if maxBody > 0 {
+		r = &limitedBody{r: r, remaining: int64(maxBody)}
+	}
+
+	body.Reader = r
+
+	return body
+}
+
+// errStreamDetached is returned by the body stream of a handler still reading
+// it after its timeout
+var errStreamDetached = errors.New("Request body stream detached from the connection after the handler timeout")
+
+// detachableReader is a reader which can be detached from its source, after
+// which its reads fail
+type detachableReader struct {
+	mu       sync.Mutex
+	r        io.Reader
+	detached bool
+}
+
+func (d *detachableReader) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.detached == true {
+		return 0, errStreamDetached
+	}
+
+	return d.r.Read(p)
+}
+
+// detach wait for the read in progress and make the next ones fail
+func (d *detachableReader) detach() {
+	d.mu.Lock()
+	d.detached = true
+	d.mu.Unlock()
+}
+
+// limitedBody is a reader returning errStreamTooLarge once more than remaining
+// bytes are read
+type limitedBody struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.exceeded == true {
+		return 0, errStreamTooLarge
+	}
+
+	// one more byte than the limit is read to know if the limit is exceeded
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+
+	if int64(n) > l.remaining {
+		l.exceeded = true
+
+		return int(l.remaining), errStreamTooLarge
+	}
+
+	l.remaining -= int64(n)
+
+	return n, err
+}
+
+// errReader is a reader always returning its error
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,165 @@
+package thttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+func TestLimitedBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		limit      int64
+		wantBody   string
+		wantTooBig bool
+	}{
+		{name: "within the limit", body: "body", limit: 10, wantBody: "body"},
+		{name: "exactly the limit", body: "body", limit: 4, wantBody: "body"},
+		{name: "over the limit", body: "body too large", limit: 4, wantBody: "body", wantTooBig: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := ioutil.ReadAll(&limitedBody{r: strings.NewReader(tt.body), remaining: tt.limit})
+
+			if (err == errStreamTooLarge) != tt.wantTooBig || (tt.wantTooBig == false && err != nil) {
+				t.Errorf("limitedBody.Read() err = %v, wantTooBig %v", err, tt.wantTooBig)
+			}
+
+			if string(body) != tt.wantBody {
+				t.Errorf("limitedBody.Read() body = %s, want %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRouteHandler_checkStream(t *testing.T) {
+	tests := []struct {
+		name            string
+		rHandler        routeHandler
+		contentLength   int
+		contentType     string
+		contentEncoding string
+		wantStatusCode  int
+	}{
+		{
+			name:          "no option",
+			rHandler:      routeHandler{streamBody: true},
+			contentLength: 1 << 30,
+		},
+		{
+			name:           "content length too large",
+			rHandler:       routeHandler{streamBody: true, maxBody: 10},
+			contentLength:  11,
+			wantStatusCode: fasthttp.StatusRequestEntityTooLarge,
+		},
+		{
+			name:          "chunked body",
+			rHandler:      routeHandler{streamBody: true, maxBody: 10},
+			contentLength: -1,
+		},
+		{
+			name:           "unsupported content type of a chunked body",
+			rHandler:       routeHandler{streamBody: true, contentTypes: []string{"application/pdf"}},
+			contentLength:  -1,
+			contentType:    "text/plain",
+			wantStatusCode: fasthttp.StatusUnsupportedMediaType,
+		},
+		{
+			name:            "unsupported encoding",
+			rHandler:        routeHandler{streamBody: true},
+			contentLength:   10,
+			contentEncoding: "gzip, compress",
+			wantStatusCode:  fasthttp.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.SetContentLength(tt.contentLength)
+			httpCtx.Request.Header.SetContentType(tt.contentType)
+			httpCtx.Request.Header.Set("Content-Encoding", tt.contentEncoding)
+
+			statusCode, err := tt.rHandler.checkRequest(httpCtx, DefaultMaxDecompressedSize)
+
+			if statusCode != tt.wantStatusCode || (err != nil) != (tt.wantStatusCode != 0) {
+				t.Errorf("routeHandler.checkRequest() = %v, %v, want %v", statusCode, err, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestRouteHandler_bodyStream(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write([]byte("decompressed body"))
+	gw.Close()
+
+	tests := []struct {
+		name                string
+		maxBody             int
+		maxDecompressedSize int
+		body                []byte
+		contentEncoding     string
+		wantBody            string
+		wantStatus          int
+	}{
+		{name: "plain body", body: []byte("body"), wantBody: "body"},
+		{name: "compressed body", body: gzipped.Bytes(), contentEncoding: "gzip", wantBody: "decompressed body"},
+		{name: "decompressed body too large", maxBody: 10, body: gzipped.Bytes(), contentEncoding: "gzip", wantStatus: 413},
+		{name: "invalid compressed body", body: []byte("not gzip"), contentEncoding: "gzip", wantStatus: 400},
+		{name: "decompressed body over the decompressed limit", maxDecompressedSize: 10, body: gzipped.Bytes(), contentEncoding: "gzip", wantStatus: 413},
+		{name: "decompressed limit lower than the max body", maxBody: 100, maxDecompressedSize: 10, body: gzipped.Bytes(), contentEncoding: "gzip", wantStatus: 413},
+		{name: "plain body ignoring the decompressed limit", maxDecompressedSize: 2, body: []byte("body"), wantBody: "body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.SetBody(tt.body)
+			httpCtx.Request.Header.Set("Content-Encoding", tt.contentEncoding)
+
+			rHandler := routeHandler{streamBody: true, maxBody: tt.maxBody}
+			stream := rHandler.bodyStream(httpCtx, tt.maxDecompressedSize)
+			defer stream.close()
+
+			req := nanux.Request{M: map[string]interface{}{"bodyStream": stream}}
+			body, err := ioutil.ReadAll(BodyStream(req))
+
+			if tt.wantStatus != 0 {
+				if err == nil || errorStatusCode(err) != tt.wantStatus {
+					t.Errorf("BodyStream() err = %v, want status %d", err, tt.wantStatus)
+				}
+
+				return
+			}
+
+			if err != nil || string(body) != tt.wantBody {
+				t.Errorf("BodyStream() = %s, %v, want %s", body, err, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDetachableReader(t *testing.T) {
+	r := &detachableReader{r: bytes.NewReader([]byte("body"))}
+
+	buf := make([]byte, 2)
+
+	if n, err := r.Read(buf); n != 2 || err != nil {
+		t.Errorf("detachableReader.Read() = %d, %v, want 2, nil", n, err)
+	}
+
+	r.detach()
+
+	if n, err := r.Read(buf); n != 0 || err != errStreamDetached {
+		t.Errorf("detachableReader.Read() after detach = %d, %v, want 0, %v", n, err, errStreamDetached)
+	}
+}
@@ -1,10 +1,14 @@
 package thttp
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 
 	"github.com/nanux-io/nanux"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
 )
@@ -22,92 +26,217 @@ type Transporter struct {
 	Server *fasthttp.Server
 
 	okOptions     bool
-	routeHandlers map[httpRoute]nanux.THandler
+	routeHandlers map[httpRoute]routeHandler
 	errHandler    nanux.ErrorHandler
 	closeChan     chan bool
+
+	// logger is set by WithLogger, the global logger of zerolog is used
+	// otherwise
+	logger *zerolog.Logger
+
+	// paramRoutes are the routes with parameters (eg: /orders/:id) in the order
+	// they were added
+	paramRoutes []httpRoute
+
+	// ctx is the parent context of all the requests, it is cancelled when the
+	// transporter is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// streamBody tells if a route streams its body, the server is then
+	// configured to stream the bodies when it runs
+	streamBody bool
+
+	maxDecompressedSize int
+	middlewares         []nanux.Middleware
+	trustedProxies      []*net.IPNet
+	codecs              []Codec
 }
 
 // Run start the http server and make it listens on the transporter's url
 func (t *Transporter) Run() (err error) {
-	t.Server.Handler = func(ctx *fasthttp.RequestCtx) {
-		var resp []byte
-		var err error
-		method := string(ctx.Method())
+	t.Server.Handler = t.handleRequest
 
-		log.Debug().Msgf("Receive request for path: %s and method : %s", ctx.Path(), ctx.Method())
+	// the server can not be configured once it serves
+	if t.streamBody == true {
+		t.enableStreaming()
+	}
 
-		// if option okOptions is set on the transporter then respond 200 to all
-		// option request
-		if t.okOptions == true && method == fasthttp.MethodOptions {
-			ctx.SetStatusCode(200)
-			ctx.SetConnectionClose()
-			return
-		}
+	t.getLogger().Info().Msgf("Start listening incoming http request at %s", t.url)
 
-		key := httpRoute{
-			route:  string(ctx.Path()),
-			method: method,
-		}
+	return t.Server.ListenAndServe(t.url)
+}
 
-		tHandler, ok := t.routeHandlers[key]
+// handleRequest is the fasthttp handler of the server. It finds the handler
+// associated to the route of the request, enforces its options and calls it.
+func (t *Transporter) handleRequest(ctx *fasthttp.RequestCtx) {
+	var resp []byte
+	var err error
+	method := string(ctx.Method())
+
+	// the client is also stored in the fasthttp context for the functions which
+	// only have access to it (eg: the key functions of RateLimit)
+	client := t.resolveClient(ctx)
+	ctx.SetUserValue(clientUserValue, client)
+
+	// each request has its own child logger so that handlers can log with the
+	// request fields without having to add them by themselves
+	reqLogger := t.getLogger().With().
+		Str("method", method).
+		Bytes("path", ctx.Path()).
+		Str("ip", client.IP.String()).
+		Logger()
+
+	reqLogger.Debug().Msgf("Receive request for path: %s and method : %s", ctx.Path(), ctx.Method())
+
+	// if option okOptions is set on the transporter then respond 200 to all
+	// option request
+	if t.okOptions == true && method == fasthttp.MethodOptions {
+		ctx.SetStatusCode(200)
+		ctx.SetConnectionClose()
+		return
+	}
 
-		// if handler not found for path then response with status code 404 is sent
-		if ok == false {
-			ctx.SetStatusCode(404)
+	rHandler, pathParams, ok := t.findRoute(string(ctx.Path()), method)
+
+	// if handler not found for path then response with status code 404 is sent
+	if ok == false {
+		ctx.SetStatusCode(404)
+		ctx.SetConnectionClose()
+		return
+	}
+
+	// the routes which do not stream their body receive it in memory
+	if rHandler.streamBody == false {
+		if statusCode, err := t.readBody(ctx); err != nil {
+			reqLogger.Debug().Err(err).Msgf("Request rejected with status code %d", statusCode)
+			ctx.SetStatusCode(statusCode)
 			ctx.SetConnectionClose()
 			return
 		}
+	}
 
-		// create nanux request and provide it with the fasthttp context
-		req := nanux.Request{
-			Data: ctx.Request.Body(),
-			M:    map[string]interface{}{"httpCtx": ctx},
-		}
+	// the options of the route are enforced before the handler is called
+	if statusCode, err := rHandler.checkRequest(ctx, t.maxDecompressedSize); err != nil {
+		reqLogger.Debug().Err(err).Msgf("Request rejected with status code %d", statusCode)
+		ctx.SetStatusCode(statusCode)
+		ctx.SetConnectionClose()
+		return
+	}
 
-		resp, err = tHandler.Fn(req)
+	reqCtx, cancel := t.requestContext(ctx, rHandler)
+	defer cancel()
 
-		// in case of error during the execution of the handler, the error handler
-		// is called if it is defined, otherwise a 500 status code is set and the
-		// response is sent
-		if err != nil {
-			if t.errHandler == nil {
-				ctx.SetStatusCode(500)
-				ctx.SetConnectionClose()
-				return
-			}
+	var body []byte
+
+	if rHandler.streamBody == false {
+		body = ctx.Request.Body()
+	}
+
+	// create nanux request and provide it with the fasthttp context
+	req := nanux.Request{
+		Data: body,
+		M: map[string]interface{}{
+			"httpCtx":     ctx,
+			"logger":      &reqLogger,
+			"context":     reqCtx,
+			"handlerOpts": rHandler.Opts,
+			"client":      client,
+			"route":       rHandler.route,
+			"pathParams":  pathParams,
+			"codecs":      t.codecs,
+			"response":    NewResponse(),
+		},
+	}
+
+	// the body stream belongs to the handler, its decoders are released once
+	// the handler has returned
+	var stream *streamedBody
+	var release func()
+
+	if rHandler.streamBody == true {
+		stream = rHandler.bodyStream(ctx, t.maxDecompressedSize)
+		release = stream.close
+
+		req.M["bodyStream"] = stream
+	}
+
+	resp, err = rHandler.call(reqCtx, req, release)
+
+	// the handler has not responded in time. The response is sent while the
+	// handler is still running so the fasthttp context must not be reused, and
+	// the connection is closed since the body of the request may not have been
+	// read. The body stream of the handler no longer reads the connection.
+	if err == errHandlerTimeout {
+		reqLogger.Warn().Err(reqCtx.Err()).Msg("Handler did not respond before the end of the request context")
 
-			resp = t.errHandler(err, req)
+		if stream != nil {
+			stream.detach(ctx)
+		}
 
-			// if the error handler return a response then the body is set to this value
-			// and the status code is set to 500
-			if resp != nil {
-				ctx.SetStatusCode(500)
-				ctx.SetBody(resp)
+		timeoutResp := &fasthttp.Response{}
+		timeoutResp.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		timeoutResp.SetBodyString("Service Unavailable")
+		timeoutResp.SetConnectionClose()
+		ctx.TimeoutErrorWithResponse(timeoutResp)
+		return
+	}
+
+	// the status code, headers and cookies set by the handler on the response
+	GetResponse(req).apply(ctx)
+
+	// in case of error during the execution of the handler, the error handler
+	// is called if it is defined, otherwise the status code of the error (500
+	// by default) is set and the response is sent
+	if err != nil {
+		statusCode := errorStatusCode(err)
+
+		if t.errHandler == nil {
+			ctx.SetStatusCode(statusCode)
+
+			// the errors defining their status code are meant for the client,
+			// without the errors wrapping them
+			if statusCode != 500 {
+				if body, jsonErr := json.Marshal(statusError(err)); jsonErr == nil {
+					ctx.SetContentType("application/json")
+					ctx.SetBody(body)
+				}
 			}
 
 			ctx.SetConnectionClose()
-
 			return
 		}
 
-		// if the handler return a non null value then the body of the response is
-		// set with this value
+		resp = t.errHandler(err, req)
+
+		// if the error handler return a response then the body is set to this value
+		// and the status code is set to the one of the error
 		if resp != nil {
+			ctx.SetStatusCode(statusCode)
 			ctx.SetBody(resp)
 		}
 
 		ctx.SetConnectionClose()
+
 		return
 	}
 
-	log.Info().Msgf("Start listening incoming http request at %s", t.url)
+	// if the handler return a non null value then the body of the response is
+	// set with this value
+	if resp != nil {
+		ctx.SetBody(resp)
+	}
 
-	return t.Server.ListenAndServe(t.url)
+	ctx.SetConnectionClose()
+	return
 }
 
 // Close the http server
 func (t *Transporter) Close() (err error) {
-	log.Info().Msgf("Http server stop serving current request and stop listening at %s", t.url)
+	t.getLogger().Info().Msgf("Http server stop serving current request and stop listening at %s", t.url)
+
+	// notify the running handlers that the transporter is closing
+	t.cancel()
 
 	if err = t.Server.Shutdown(); err != nil {
 		return err
@@ -122,7 +251,7 @@ func (t *Transporter) Handle(route string, tHandler nanux.THandler) error {
 
 	if ok == false {
 		errMsg := fmt.Sprintf("Missing http method for route : %s", route)
-		log.Error().Msg(errMsg)
+		t.getLogger().Error().Msg(errMsg)
 
 		return errors.New(errMsg)
 	}
@@ -131,22 +260,40 @@ func (t *Transporter) Handle(route string, tHandler nanux.THandler) error {
 
 	if ok == false {
 		errMsg := "Option associated to thttp.MethodsOpt is not of type thttp.Methods"
-		log.Error().Msg(errMsg)
+		t.getLogger().Error().Msg(errMsg)
 
 		return errors.New(errMsg)
 	}
 
+	rHandler, err := newRouteHandler(tHandler, t.middlewares)
+
+	if err != nil {
+		t.getLogger().Error().Msg(err.Error())
+
+		return err
+	}
+
+	rHandler.route = route
+
 	httpRoutes := methods.getHTTPRoutes(route)
 
 	for _, httpRoute := range httpRoutes {
 		if _, ok := t.routeHandlers[httpRoute]; ok == true {
 			errMsg := "An handler is already associated to this route"
-			log.Error().Msg(errMsg)
+			t.getLogger().Error().Msg(errMsg)
 
 			return errors.New(errMsg)
 		}
 
-		t.routeHandlers[httpRoute] = tHandler
+		t.routeHandlers[httpRoute] = rHandler
+
+		if hasPathParams(route) == true {
+			t.paramRoutes = append(t.paramRoutes, httpRoute)
+		}
+	}
+
+	if rHandler.streamBody == true {
+		t.streamBody = true
 	}
 
 	return nil
@@ -156,7 +303,7 @@ func (t *Transporter) Handle(route string, tHandler nanux.THandler) error {
 func (t *Transporter) HandleError(errHandler nanux.ErrorHandler) (err error) {
 	if t.errHandler != nil {
 		errMsg := "An error handler has already been set"
-		log.Error().Msg(errMsg)
+		t.getLogger().Error().Msg(errMsg)
 
 		return errors.New(errMsg)
 	}
@@ -170,14 +317,58 @@ func (t *Transporter) HandleError(errHandler nanux.ErrorHandler) (err error) {
   Instantiation of tHTTP transporter
 \*----------------------------------------------------------------------------*/
 
+// Option configures the transporter at its creation
+type Option func(*Transporter)
+
+// getLogger return the logger of the transporter. The global logger of zerolog
+// is read when it is used so that it can be configured after the transporter
+// is created.
+func (t *Transporter) getLogger() *zerolog.Logger {
+	if t.logger != nil {
+		return t.logger
+	}
+
+	return &log.Logger
+}
+
+// WithLogger set the logger used by the transporter. A child logger of it is
+// created for each request and injected in `req.M["logger"]`. By default the
+// global logger of zerolog is used.
+func WithLogger(logger zerolog.Logger) Option {
+	return func(t *Transporter) {
+		t.logger = &logger
+	}
+}
+
+// WithMiddlewares set middlewares executed for all the routes, before the
+// handler and its own middlewares. The authorization options of the routes are
+// checked after them, so the authentication middlewares must be set here to
+// use these options. The nanux context provided to these middlewares is nil.
+func WithMiddlewares(middlewares ...nanux.Middleware) Option {
+	return func(t *Transporter) {
+		t.middlewares = append(t.middlewares, middlewares...)
+	}
+}
+
 // New returns a new instance of http transporter which will listen to the specified url.
 // The param okOption is a little helper to tell the transporter to respond ok to all
 // options.
-func New(url string, okOptions bool) Transporter {
-	return Transporter{
+func New(url string, okOptions bool, opts ...Option) Transporter {
+	t := Transporter{
 		url:           url,
 		Server:        &fasthttp.Server{},
-		routeHandlers: make(map[httpRoute]nanux.THandler),
+		routeHandlers: make(map[httpRoute]routeHandler),
 		okOptions:     okOptions,
+
+		maxDecompressedSize: DefaultMaxDecompressedSize,
+		codecs:              []Codec{JSONCodec{}},
 	}
+
+	for _, opt := range opts {
+		opt(&t)
+	}
+
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+
+	return t
 }
@@ -1,13 +1,22 @@
 package thttp_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/rs/zerolog"
 	"github.com/valyala/fasthttp"
 
 	"github.com/nanux-io/nanux"
@@ -30,10 +39,15 @@ var _ = Describe("tHTTP transporter", func() {
 		var (
 			t         Transporter
 			okOptions bool
+			opts      []Option
 		)
 
+		BeforeEach(func() {
+			opts = nil
+		})
+
 		JustBeforeEach(func() {
-			t = New(url, okOptions)
+			t = New(url, okOptions, opts...)
 		})
 
 		It("should launch an http server on the specified url and close", func(done Done) {
@@ -187,6 +201,74 @@ var _ = Describe("tHTTP transporter", func() {
 				close(done)
 			}, 0.5)
 
+			Context("with a logger", func() {
+				logs := &syncBuffer{}
+
+				BeforeEach(func() {
+					opts = []Option{WithLogger(zerolog.New(logs))}
+				})
+
+				It("should inject a request scoped logger into Request.M of handler", func() {
+					route := "/test/logger"
+					tHandler := nanux.THandler{
+						Fn: func(req nanux.Request) ([]byte, error) {
+							GetLogger(req).Info().Msg("log from handler")
+
+							return nil, nil
+						},
+						Opts: methodGetOpt,
+					}
+
+					err := t.Handle(route, tHandler)
+					Expect(err).ToNot(HaveOccurred())
+
+					resp, err := httpClient.Get("http://" + url + route)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(200))
+
+					Expect(logs.String()).To(ContainSubstring(`{"level":"info","method":"GET","path":"/test/logger","ip":"127.0.0.1","message":"log from handler"}`))
+				})
+			})
+
+			Context("behind trusted proxies", func() {
+				route := "/test/client"
+
+				BeforeEach(func() {
+					proxies, err := ParseCIDRs("127.0.0.1", "10.0.0.0/8")
+					Expect(err).ToNot(HaveOccurred())
+
+					opts = []Option{WithTrustedProxies(proxies...)}
+				})
+
+				JustBeforeEach(func() {
+					tHandler := nanux.THandler{
+						Fn: func(req nanux.Request) ([]byte, error) {
+							client := GetClient(req)
+
+							return []byte(client.IP.String() + " " + client.Scheme + " " + client.Host), nil
+						},
+						Opts: methodGetOpt,
+					}
+
+					err := t.Handle(route, tHandler)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("should resolve the client from the forwarding headers of the trusted proxies", func() {
+					req, err := http.NewRequest(http.MethodGet, "http://"+url+route, nil)
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7, 10.0.0.1")
+					req.Header.Set("X-Forwarded-Proto", "https, http")
+					req.Header.Set("X-Forwarded-Host", "api.example.com, internal")
+
+					resp, err := httpClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					body, _ := readResponseBody(resp)
+					Expect(body).To(Equal("203.0.113.7 https api.example.com"))
+				})
+			})
+
 			It("should set into the response body the value returned by the handler", func() {
 				handlerMsg := "message coming from my handler"
 				route := "/myroute"
@@ -208,6 +290,353 @@ var _ = Describe("tHTTP transporter", func() {
 				Expect(body).To(Equal(handlerMsg))
 			})
 
+			It("should apply the status code, headers and cookies set on the response by the handler", func() {
+				route := "/test/response"
+				tHandler := nanux.THandler{
+					Fn: func(req nanux.Request) ([]byte, error) {
+						resp := GetResponse(req)
+						resp.Redirect("/orders/42", 201)
+						resp.SetCookie(&http.Cookie{Name: "theme", Value: "dark"})
+
+						return []byte("created"), nil
+					},
+					Opts: nanux.HandlerOpts{MethodsOpt: Methods{Post: true}},
+				}
+
+				err := t.Handle(route, tHandler)
+				Expect(err).ToNot(HaveOccurred())
+
+				resp, err := httpClient.Post("http://"+url+route, "text/plain", nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(201))
+				Expect(resp.Header.Get("Location")).To(Equal("/orders/42"))
+				Expect(resp.Header.Get("Set-Cookie")).To(Equal("theme=dark"))
+
+				body, _ := readResponseBody(resp)
+				Expect(body).To(Equal("created"))
+			})
+
+			Context("when the request body is compressed", func() {
+				route := "/test/compressed"
+				handlerMsg := "message sent compressed to my handler"
+
+				JustBeforeEach(func() {
+					tHandler := nanux.THandler{
+						Fn: func(req nanux.Request) ([]byte, error) {
+							return req.Data, nil
+						},
+						Opts: nanux.HandlerOpts{MethodsOpt: Methods{Post: true}},
+					}
+
+					err := t.Handle(route, tHandler)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("should provide the decompressed body to the handler", func() {
+					var body bytes.Buffer
+					w := gzip.NewWriter(&body)
+					w.Write([]byte(handlerMsg))
+					w.Close()
+
+					req, err := http.NewRequest(http.MethodPost, "http://"+url+route, &body)
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("Content-Encoding", "gzip")
+
+					resp, err := httpClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(200))
+
+					respBody, _ := readResponseBody(resp)
+					Expect(respBody).To(Equal(handlerMsg))
+				})
+
+				It("should respond with 415 status for unsupported encoding", func() {
+					req, err := http.NewRequest(http.MethodPost, "http://"+url+route, bytes.NewBufferString(handlerMsg))
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("Content-Encoding", "compress")
+
+					resp, err := httpClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(415))
+				})
+			})
+
+			Context("with a request context", func() {
+				route := "/test/context"
+				routeFullUrl := "http://" + url + route
+				ctxErrC := make(chan error, 1)
+
+				JustBeforeEach(func() {
+					tHandler := nanux.THandler{
+						Fn: func(req nanux.Request) ([]byte, error) {
+							ctx := GetContext(req)
+
+							select {
+							case <-ctx.Done():
+							case <-time.After(200 * time.Millisecond):
+							}
+
+							ctxErrC <- ctx.Err()
+
+							return nil, nil
+						},
+						Opts: methodGetOpt,
+					}
+
+					err := t.Handle(route, tHandler)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("should cancel the context when the timeout sent by the client is reached", func() {
+					req, err := http.NewRequest(http.MethodGet, routeFullUrl, nil)
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set(RequestTimeoutHeader, "0.02")
+
+					resp, err := httpClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(503))
+					Expect(<-ctxErrC).To(Equal(context.DeadlineExceeded))
+				})
+
+				It("should cancel the context when the transporter is closed", func() {
+					go httpClient.Get(routeFullUrl)
+
+					// wait to let time to the request to reach the handler
+					time.Sleep(20 * time.Millisecond)
+					t.Close()
+
+					Expect(<-ctxErrC).To(Equal(context.Canceled))
+				})
+
+				It("should cancel the context when the client disconnects", func() {
+					conn, err := net.Dial("tcp", url)
+					Expect(err).ToNot(HaveOccurred())
+
+					_, err = conn.Write([]byte("GET " + route + " HTTP/1.1\r\nHost: " + url + "\r\n\r\n"))
+					Expect(err).ToNot(HaveOccurred())
+
+					// wait to let time to the request to reach the handler
+					time.Sleep(10 * time.Millisecond)
+					conn.Close()
+
+					Expect(<-ctxErrC).To(Equal(context.Canceled))
+				})
+			})
+
+			Context("with route options", func() {
+				route := "/test/options"
+				routeFullUrl := "http://" + url + route
+
+				JustBeforeEach(func() {
+					tHandler := nanux.THandler{
+						Fn: func(req nanux.Request) ([]byte, error) {
+							if string(req.Data) == "slow" {
+								time.Sleep(60 * time.Millisecond)
+							}
+
+							return req.Data, nil
+						},
+						Opts: nanux.HandlerOpts{
+							MethodsOpt:      Methods{Post: true},
+							MaxBodyOpt:      10,
+							TimeoutOpt:      20 * time.Millisecond,
+							ContentTypesOpt: []string{"text/plain"},
+						},
+					}
+
+					err := t.Handle(route, tHandler)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("should call the handler when the request respects the options", func() {
+					resp, err := httpClient.Post(routeFullUrl, "text/plain", bytes.NewBufferString("body"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(200))
+
+					body, _ := readResponseBody(resp)
+					Expect(body).To(Equal("body"))
+				})
+
+				It("should respond with 413 status when the body is too large", func() {
+					resp, err := httpClient.Post(routeFullUrl, "text/plain", bytes.NewBufferString("body too large"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(413))
+				})
+
+				It("should respond with 415 status when the content type is not accepted", func() {
+					resp, err := httpClient.Post(routeFullUrl, "application/json", bytes.NewBufferString("{}"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(415))
+				})
+
+				It("should respond with 503 status when the handler exceeds its timeout", func() {
+					resp, err := httpClient.Post(routeFullUrl, "text/plain", bytes.NewBufferString("slow"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(503))
+				})
+
+				It("should fail to add a handler with an option of wrong type", func() {
+					tHandlerWithWrongOpt := nanux.THandler{
+						Fn: func(nanux.Request) ([]byte, error) {
+							return nil, nil
+						},
+						Opts: nanux.HandlerOpts{MethodsOpt: Methods{Get: true}, TimeoutOpt: "1s"},
+					}
+
+					err := t.Handle("/test/wrongopt", tHandlerWithWrongOpt)
+					Expect(err).To(HaveOccurred())
+				})
+			})
+
+			Context("with a typed handler on a route with parameters", func() {
+				type getOrderInput struct {
+					ID     int  `path:"id" validate:"min=1"`
+					Expand bool `query:"expand"`
+				}
+
+				type order struct {
+					ID     int  `json:"id"`
+					Expand bool `json:"expand"`
+				}
+
+				BeforeEach(func() {
+					opts = []Option{WithCodecs(MsgPackCodec{})}
+				})
+
+				JustBeforeEach(func() {
+					tHandler := Typed(func(_ context.Context, in getOrderInput) (order, error) {
+						return order{ID: in.ID, Expand: in.Expand}, nil
+					})
+					tHandler.Opts[MethodsOpt] = Methods{Get: true}
+
+					err := t.Handle("/orders/:id", tHandler)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("should decode the path parameters and the query and encode the result in JSON", func() {
+					resp, err := httpClient.Get("http://" + url + "/orders/42?expand=true")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(200))
+					Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+					body, _ := readResponseBody(resp)
+					Expect(body).To(Equal(`{"id":42,"expand":true}`))
+				})
+
+				It("should encode the result with the codec accepted by the client", func() {
+					req, err := http.NewRequest(http.MethodGet, "http://"+url+"/orders/42", nil)
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("Accept", "application/msgpack")
+
+					resp, err := httpClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(200))
+					Expect(resp.Header.Get("Content-Type")).To(Equal("application/msgpack"))
+
+					body, _ := readResponseBody(resp)
+
+					var o order
+					err = MsgPackCodec{}.Unmarshal([]byte(body), &o)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(o).To(Equal(order{ID: 42}))
+				})
+
+				It("should respond with 406 status when the client accepts none of the codecs", func() {
+					req, err := http.NewRequest(http.MethodGet, "http://"+url+"/orders/42", nil)
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("Accept", "text/html")
+
+					resp, err := httpClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(406))
+				})
+
+				It("should respond with 422 status when the input is not valid", func() {
+					resp, err := httpClient.Get("http://" + url + "/orders/0")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(422))
+				})
+
+				It("should respond with 404 status when the path does not match the route", func() {
+					resp, err := httpClient.Get("http://" + url + "/orders/42/items")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(404))
+				})
+			})
+
+			Context("with authorization options", func() {
+				route := "/test/authz"
+				routeFullUrl := "http://" + url + route
+
+				BeforeEach(func() {
+					authenticate := BearerAuth(BearerAuthConfig{
+						Validate: func(token string) (Principal, error) {
+							if token == "reader" {
+								return Principal{ID: "alice", Scopes: []string{"orders:read"}}, nil
+							}
+
+							if token == "writer" {
+								return Principal{ID: "bob", Scopes: []string{"orders:read", "orders:write"}}, nil
+							}
+
+							return Principal{}, errors.New("unknown token")
+						},
+					})
+
+					opts = []Option{WithMiddlewares(authenticate)}
+				})
+
+				JustBeforeEach(func() {
+					tHandler := nanux.THandler{
+						Fn: func(req nanux.Request) ([]byte, error) {
+							return []byte("created"), nil
+						},
+						Opts: nanux.HandlerOpts{
+							MethodsOpt:       Methods{Post: true},
+							RequireScopesOpt: []string{"orders:write"},
+						},
+					}
+
+					err := t.Handle(route, tHandler)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				post := func(token string) *http.Response {
+					req, err := http.NewRequest(http.MethodPost, routeFullUrl, nil)
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("Authorization", "Bearer "+token)
+
+					resp, err := httpClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+
+					return resp
+				}
+
+				It("should call the handler when the principal meets the requirements", func() {
+					resp := post("writer")
+					Expect(resp.StatusCode).To(Equal(200))
+
+					body, _ := readResponseBody(resp)
+					Expect(body).To(Equal("created"))
+				})
+
+				It("should respond with 403 status when the principal misses a scope", func() {
+					resp := post("reader")
+					Expect(resp.StatusCode).To(Equal(403))
+				})
+
+				It("should respond with 401 status when the request is not authenticated", func() {
+					resp := post("unknown")
+					Expect(resp.StatusCode).To(Equal(401))
+				})
+
+				It("should list the route with its requirements", func() {
+					Expect(t.Routes()).To(Equal([]RouteInfo{
+						{Route: route, Method: "POST", Scopes: []string{"orders:write"}},
+					}))
+				})
+			})
+
 			It("should only respond to methods (GET, DELETE) set into the options of the handler", func() {
 				route := "/myroute"
 				tHandler := nanux.THandler{
@@ -272,6 +701,67 @@ var _ = Describe("tHTTP transporter", func() {
 					Expect(body).To(Equal(""))
 				})
 
+				Context("when the error defines its status code", func() {
+					JustBeforeEach(func() {
+						tHandler := nanux.THandler{
+							Fn: func(req nanux.Request) ([]byte, error) {
+								var order struct {
+									Name string `json:"name" validate:"required"`
+								}
+
+								return nil, Bind(req, &order)
+							},
+							Opts: nanux.HandlerOpts{MethodsOpt: Methods{Post: true}},
+						}
+
+						err := t.Handle("/test/bind", tHandler)
+						Expect(err).ToNot(HaveOccurred())
+
+						tHandler = nanux.THandler{
+							Fn: func(req nanux.Request) ([]byte, error) {
+								return nil, fmt.Errorf("lookup : %w", &Error{Status: 404, Message: "order not found"})
+							},
+							Opts: methodGetOpt,
+						}
+
+						err = t.Handle("/test/wrapped", tHandler)
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("should respond with the status code and the error encoded in JSON", func() {
+						resp, err := httpClient.Post("http://"+url+"/test/bind", "application/json", bytes.NewBufferString(`{"name":""}`))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(resp.StatusCode).To(Equal(422))
+						Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+						body, _ := readResponseBody(resp)
+						Expect(body).To(Equal(`{"error":"Validation failed","fields":[{"field":"name","rule":"required","message":"is required"}]}`))
+					})
+
+					It("should encode the error defining the status code when it is wrapped", func() {
+						resp, err := httpClient.Get("http://" + url + "/test/wrapped")
+						Expect(err).ToNot(HaveOccurred())
+						Expect(resp.StatusCode).To(Equal(404))
+
+						body, _ := readResponseBody(resp)
+						Expect(body).To(Equal(`{"error":"order not found"}`))
+					})
+
+					It("should respond with the status code and the value provided by the error handler", func() {
+						err := t.HandleError(func(err error, req nanux.Request) []byte {
+							return []byte(err.Error())
+						})
+						Expect(err).ToNot(HaveOccurred())
+
+						resp, err := httpClient.Post("http://"+url+"/test/bind", "application/json", bytes.NewBufferString(`{"name":`))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(resp.StatusCode).To(Equal(400))
+
+						body, _ := readResponseBody(resp)
+						Expect(body).To(HavePrefix("Invalid application/json body"))
+					})
+				})
+
 				Context("when the error handler return a value", func() {
 					It("should respond with the value provided by the error handler and with 500 status code", func() {
 						errHandler := func(err error, req nanux.Request) []byte {
@@ -329,9 +819,120 @@ var _ = Describe("tHTTP transporter", func() {
 				t.Close()
 			})
 		})
+
+		Context("with a route streaming its body", func() {
+			streamURL := "http://" + url + "/test/stream"
+			bufferedURL := "http://" + url + "/test/buffered"
+
+			// the server is configured and the routes streaming their body are
+			// added before it runs
+			JustBeforeEach(func() {
+				t.Server.MaxRequestBodySize = 64
+
+				streamHandler := nanux.THandler{
+					Fn: func(req nanux.Request) ([]byte, error) {
+						if len(req.Data) != 0 {
+							return nil, errors.New("body read in memory")
+						}
+
+						body, err := ioutil.ReadAll(BodyStream(req))
+
+						if err != nil {
+							return nil, err
+						}
+
+						return []byte(strconv.Itoa(len(body))), nil
+					},
+					Opts: nanux.HandlerOpts{
+						MethodsOpt:    Methods{Post: true},
+						MaxBodyOpt:    1000,
+						StreamBodyOpt: true,
+					},
+				}
+
+				err := t.Handle("/test/stream", streamHandler)
+				Expect(err).ToNot(HaveOccurred())
+
+				bufferedHandler := nanux.THandler{
+					Fn: func(req nanux.Request) ([]byte, error) {
+						return req.Data, nil
+					},
+					Opts: nanux.HandlerOpts{MethodsOpt: Methods{Post: true}},
+				}
+
+				err = t.Handle("/test/buffered", bufferedHandler)
+				Expect(err).ToNot(HaveOccurred())
+
+				go t.Run()
+
+				// wait to let time to the server to be launched
+				time.Sleep(50 * time.Millisecond)
+			})
+
+			AfterEach(func() {
+				t.Close()
+			})
+
+			It("should provide a body larger than the limit of the server as a stream", func() {
+				resp, err := httpClient.Post(streamURL, "text/plain", bytes.NewReader(make([]byte, 500)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+
+				body, _ := readResponseBody(resp)
+				Expect(body).To(Equal("500"))
+			})
+
+			It("should respond with 413 status when the content length exceeds the limit of the route", func() {
+				resp, err := httpClient.Post(streamURL, "text/plain", bytes.NewReader(make([]byte, 2000)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(413))
+			})
+
+			It("should respond with 413 status when a chunked body exceeds the limit of the route", func() {
+				chunked := struct{ io.Reader }{bytes.NewReader(make([]byte, 2000))}
+
+				resp, err := httpClient.Post(streamURL, "text/plain", chunked)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(413))
+			})
+
+			It("should keep enforcing the limit of the server on the other routes", func() {
+				resp, err := httpClient.Post(bufferedURL, "text/plain", bytes.NewReader(make([]byte, 500)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(413))
+
+				resp, err = httpClient.Post(bufferedURL, "text/plain", bytes.NewBufferString("small body"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+
+				body, _ := readResponseBody(resp)
+				Expect(body).To(Equal("small body"))
+			})
+		})
 	})
 })
 
+// syncBuffer is a bytes.Buffer safe to be written by the http server while
+// being read by the tests
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
 func readResponseBody(resp *http.Response) (body string, err error) {
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 
@@ -0,0 +1,133 @@
+package thttp
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// Typed return a transporter handler decoding the request in a value of type In,
+// calling fn with the context of the request (see GetContext) and encoding its
+// result with the codec negotiated from the Accept header (JSON by default, see
+// WithCodecs). The codec is negotiated before fn is called, so fn is not called
+// when a 406 status code error is returned.
+//
+// When In is a struct, the body is decoded according to its content type (see
+// Bind), the fields with a `path`, `query` or `header` tag are set with the
+// path parameters, the query string and the headers of the request, and the
+// struct is validated (see Validate). Otherwise the body is decoded with the
+// codec of its content type.
+//
+// The response has no body if fn returns a nil value. If the result implements
+// `StatusCode() int`, its status code is used for the response.
+//
+// The options of the handler are empty, the MethodsOpt option must be added
+// before the handler is given to the transporter.
+func Typed[In, Out any](fn func(ctx context.Context, in In) (Out, error)) nanux.THandler {
+	return nanux.THandler{
+		Fn: func(req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			codecs := GetCodecs(req)
+			codec, contentType, err := negotiateCodec(httpCtx, codecs)
+
+			if err != nil {
+				return nil, err
+			}
+
+			var in In
+
+			if err := decodeTyped(httpCtx, codecs, req, &in); err != nil {
+				return nil, err
+			}
+
+			out, err := fn(GetContext(req), in)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return encodeTyped(httpCtx, codec, contentType, out)
+		},
+		Opts: nanux.HandlerOpts{},
+	}
+}
+
+// decodeTyped decode the request in dst, a pointer to the input of a typed
+// handler
+func decodeTyped(httpCtx *fasthttp.RequestCtx, codecs []Codec, req nanux.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst).Elem()
+
+	if v.Kind() != reflect.Struct {
+		if len(req.Data) == 0 {
+			return nil
+		}
+
+		return decodeBody(httpCtx, codecs, req.Data, dst)
+	}
+
+	if len(req.Data) > 0 {
+		if err := bindBody(httpCtx, codecs, req.Data, dst); err != nil {
+			return err
+		}
+	}
+
+	params := GetPathParams(req)
+	sources := []struct {
+		tag    string
+		lookup func(name string) []string
+	}{
+		{"path", func(name string) []string {
+			if value, ok := params[name]; ok == true {
+				return []string{value}
+			}
+
+			return nil
+		}},
+		{"query", argsLookup(httpCtx.QueryArgs())},
+		{"header", func(name string) []string {
+			if value := httpCtx.Request.Header.Peek(name); len(value) > 0 {
+				return []string{string(value)}
+			}
+
+			return nil
+		}},
+	}
+
+	for _, source := range sources {
+		if err := bindValues(v, source.tag, source.lookup); err != nil {
+			return err
+		}
+	}
+
+	return Validate(dst)
+}
+
+// encodeTyped encode the result of a typed handler with the negotiated codec
+func encodeTyped(httpCtx *fasthttp.RequestCtx, codec Codec, contentType string, out interface{}) ([]byte, error) {
+	if v := reflect.ValueOf(out); out == nil || (isNillable(v.Kind()) == true && v.IsNil() == true) {
+		return nil, nil
+	}
+
+	if sc, ok := out.(statusCoder); ok == true && sc.StatusCode() > 0 {
+		httpCtx.SetStatusCode(sc.StatusCode())
+	}
+
+	return encodeBody(httpCtx, codec, contentType, out)
+}
+
+// isNillable tells if a value of the kind can be nil
+func isNillable(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package thttp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+type getOrderInput struct {
+	ID        int    `path:"id" validate:"min=1"`
+	Expand    bool   `query:"expand"`
+	RequestID string `header:"X-Request-ID"`
+	Note      string `json:"note"`
+}
+
+type order struct {
+	ID        int    `json:"id"`
+	Expand    bool   `json:"expand"`
+	RequestID string `json:"requestId"`
+	Note      string `json:"note"`
+}
+
+type createdOrder struct {
+	ID int `json:"id"`
+}
+
+func (createdOrder) StatusCode() int {
+	return 201
+}
+
+func TestTyped(t *testing.T) {
+	getOrder := Typed(func(ctx context.Context, in getOrderInput) (*order, error) {
+		if ctx == nil {
+			return nil, errors.New("missing context")
+		}
+
+		if in.ID == 404 {
+			return nil, nil
+		}
+
+		if in.ID == 500 {
+			return nil, errors.New("failure")
+		}
+
+		return &order{ID: in.ID, Expand: in.Expand, RequestID: in.RequestID, Note: in.Note}, nil
+	})
+
+	createOrder := Typed(func(_ context.Context, in []string) (createdOrder, error) {
+		return createdOrder{ID: len(in)}, nil
+	})
+
+	tests := []struct {
+		name           string
+		handler        nanux.THandler
+		params         map[string]string
+		query          string
+		headers        map[string]string
+		body           string
+		wantResp       string
+		wantStatusCode int
+		wantErrStatus  int
+	}{
+		{
+			name:           "decode path, query, headers and body",
+			handler:        getOrder,
+			params:         map[string]string{"id": "42"},
+			query:          "expand=true",
+			headers:        map[string]string{"X-Request-ID": "abc", "Content-Type": "application/json"},
+			body:           `{"note":"fragile"}`,
+			wantResp:       `{"id":42,"expand":true,"requestId":"abc","note":"fragile"}`,
+			wantStatusCode: 200,
+		},
+		{
+			name:           "nil result",
+			handler:        getOrder,
+			params:         map[string]string{"id": "404"},
+			wantStatusCode: 200,
+		},
+		{
+			name:          "invalid path parameter",
+			handler:       getOrder,
+			params:        map[string]string{"id": "abc"},
+			wantErrStatus: 400,
+		},
+		{
+			name:          "validation error",
+			handler:       getOrder,
+			params:        map[string]string{"id": "0"},
+			wantErrStatus: 422,
+		},
+		{
+			name:          "handler error",
+			handler:       getOrder,
+			params:        map[string]string{"id": "500"},
+			wantErrStatus: 500,
+		},
+		{
+			name:           "non struct input and status code of the result",
+			handler:        createOrder,
+			headers:        map[string]string{"Content-Type": "application/json"},
+			body:           `["a","b"]`,
+			wantResp:       `{"id":2}`,
+			wantStatusCode: 201,
+		},
+		{
+			name:          "invalid json for non struct input",
+			handler:       createOrder,
+			headers:       map[string]string{"Content-Type": "application/json"},
+			body:          `["a",`,
+			wantErrStatus: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.SetRequestURI("/orders?" + tt.query)
+
+			for name, value := range tt.headers {
+				httpCtx.Request.Header.Set(name, value)
+			}
+
+			req := nanux.Request{
+				Data: []byte(tt.body),
+				M:    map[string]interface{}{"httpCtx": httpCtx, "pathParams": tt.params},
+			}
+
+			resp, err := tt.handler.Fn(req)
+
+			if tt.wantErrStatus != 0 {
+				if err == nil || errorStatusCode(err) != tt.wantErrStatus {
+					t.Errorf("Typed() err = %v, want status %d", err, tt.wantErrStatus)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Typed() err = %v", err)
+			}
+
+			if string(resp) != tt.wantResp {
+				t.Errorf("Typed() resp = %s, want %s", resp, tt.wantResp)
+			}
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("Typed() status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			if tt.wantResp != "" && string(httpCtx.Response.Header.ContentType()) != "application/json" {
+				t.Errorf("Typed() content type = %s", httpCtx.Response.Header.ContentType())
+			}
+		})
+	}
+}
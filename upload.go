@@ -0,0 +1,368 @@
+package thttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+
+	"github.com/nanux-io/nanux"
+)
+
+// Default limits of the uploads
+const (
+	DefaultMaxUploadFileSize  = 32 * 1024 * 1024
+	DefaultMaxUploadSize      = 64 * 1024 * 1024
+	DefaultMaxUploadFieldSize = 1024 * 1024
+	DefaultMaxUploadFiles     = 100
+	DefaultMaxUploadParts     = 1000
+)
+
+// sniffLen is the number of bytes used to detect the content type of a file
+const sniffLen = 512
+
+// UploadConfig define the configuration of the parsing of the multipart uploads
+type UploadConfig struct {
+	// Dir is the directory of the temporary files. Default to os.TempDir().
+	Dir string
+	// MaxFileSize is the maximum size in bytes of a file. Default to
+	// DefaultMaxUploadFileSize.
+	MaxFileSize int64
+	// MaxSize is the maximum size in bytes of all the files and fields. Default
+	// to DefaultMaxUploadSize.
+	MaxSize int64
+	// MaxFieldSize is the maximum size in bytes of a field which is not a file.
+	// Default to DefaultMaxUploadFieldSize.
+	MaxFieldSize int64
+	// MaxFiles is the maximum number of files. Default to
+	// DefaultMaxUploadFiles.
+	MaxFiles int
+	// MaxParts is the maximum number of parts, files and fields. Default to
+	// DefaultMaxUploadParts.
+	MaxParts int
+	// AllowedTypes are the content types accepted for the files, detected from
+	// their content. A pattern like "image/*" matches all the subtypes. All the
+	// content types are accepted when empty.
+	AllowedTypes []string
+	// Destination return the writer of a file instead of a temporary file. It
+	// is called once the content type of the file is detected. When it returns
+	// a nil writer, a temporary file is used.
+	Destination func(file *UploadedFile) (io.Writer, error)
+}
+
+// UploadedFile is a file of a multipart upload
+type UploadedFile struct {
+	Field    string
+	Filename string
+	// Header is the header of the part, with the content type sent by the client
+	Header textproto.MIMEHeader
+	// ContentType is the content type detected from the content of the file
+	ContentType string
+	Size        int64
+	// Path is the path of the temporary file, empty when the file has been
+	// written in the writer returned by UploadConfig.Destination
+	Path string
+}
+
+// Open open the temporary file of the upload
+func (f *UploadedFile) Open() (*os.File, error) {
+	if f.Path == "" {
+		return nil, errors.New("Upload : the file has not been written in a temporary file")
+	}
+
+	return os.Open(f.Path)
+}
+
+// Upload is a parsed multipart upload
+type Upload struct {
+	Values map[string][]string
+	Files  map[string][]*UploadedFile
+}
+
+// File return the first file of the field, or nil if there is none
+func (u *Upload) File(field string) *UploadedFile {
+	if files := u.Files[field]; len(files) > 0 {
+		return files[0]
+	}
+
+	return nil
+}
+
+// RemoveAll remove the temporary files of the upload
+func (u *Upload) RemoveAll() error {
+	var err error
+
+	for _, files := range u.Files {
+		for _, file := range files {
+			if file.Path == "" {
+				continue
+			}
+
+			if rmErr := os.Remove(file.Path); rmErr != nil && os.IsNotExist(rmErr) == false {
+				err = rmErr
+			}
+		}
+	}
+
+	return err
+}
+
+// Uploads return a middleware which parses the multipart/form-data bodies,
+// writing their files in temporary files or in the writers of
+// cfg.Destination, and injects the upload in `req.M["upload"]` (see
+// GetUpload). The temporary files are removed once the handler returns.
+func Uploads(cfg UploadConfig) nanux.Middleware {
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			upload, err := ParseUpload(req, cfg)
+
+			if err != nil {
+				return nil, err
+			}
+
+			defer func() {
+				if err := upload.RemoveAll(); err != nil {
+					GetLogger(req).Error().Err(err).Msg("Uploads : could not remove the temporary files")
+				}
+			}()
+
+			req.M["upload"] = upload
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// GetUpload return the upload parsed by the Uploads middleware, or nil if
+// there is none
+func GetUpload(req nanux.Request) *Upload {
+	upload, _ := req.M["upload"].(*Upload)
+
+	return upload
+}
+
+// ParseUpload parse the multipart/form-data body of the request part by part,
+// while it is received for the routes streaming their body (see BodyStream).
+// The files are written in temporary files, which must be removed with
+// RemoveAll, or in the writers of cfg.Destination. The errors have a status
+// code: 415 when the request is not multipart or a file type is not allowed,
+// 413 when a limit is exceeded and 400 when the body is malformed.
+func ParseUpload(req nanux.Request, cfg UploadConfig) (*Upload, error) {
+	httpCtx, err := GetHTTPCtx(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(string(httpCtx.Request.Header.ContentType()))
+
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, &Error{Status: 415, Message: "Unsupported content type : multipart/form-data expected"}
+	}
+
+	if params["boundary"] == "" {
+		return nil, &Error{Status: 400, Message: "Invalid multipart body : missing boundary"}
+	}
+
+	p := newUploadParser(cfg)
+	upload, err := p.parse(multipart.NewReader(BodyStream(req), params["boundary"]))
+
+	if err != nil {
+		upload.RemoveAll()
+
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// uploadParser reads the parts of a multipart body while enforcing the limits
+// of the configuration
+type uploadParser struct {
+	cfg       UploadConfig
+	remaining int64
+	parts     int
+	files     int
+}
+
+func newUploadParser(cfg UploadConfig) *uploadParser {
+	if cfg.MaxFileSize == 0 {
+		cfg.MaxFileSize = DefaultMaxUploadFileSize
+	}
+
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = DefaultMaxUploadSize
+	}
+
+	if cfg.MaxFieldSize == 0 {
+		cfg.MaxFieldSize = DefaultMaxUploadFieldSize
+	}
+
+	if cfg.MaxFiles == 0 {
+		cfg.MaxFiles = DefaultMaxUploadFiles
+	}
+
+	if cfg.MaxParts == 0 {
+		cfg.MaxParts = DefaultMaxUploadParts
+	}
+
+	return &uploadParser{cfg: cfg, remaining: cfg.MaxSize}
+}
+
+// parse read all the parts. The upload returned contains the files written
+// before an error.
+func (p *uploadParser) parse(r *multipart.Reader) (*Upload, error) {
+	upload := &Upload{
+		Values: make(map[string][]string),
+		Files:  make(map[string][]*UploadedFile),
+	}
+
+	for {
+		part, err := r.NextPart()
+
+		if err == io.EOF {
+			return upload, nil
+		}
+
+		if err != nil {
+			return upload, &Error{Status: 400, Message: "Invalid multipart body : " + err.Error()}
+		}
+
+		// the empty parts cost nothing to the client but may each create a
+		// temporary file
+		if p.parts++; p.parts > p.cfg.MaxParts {
+			return upload, &Error{Status: 413, Message: "Too many parts (maximum " + strconv.Itoa(p.cfg.MaxParts) + ")"}
+		}
+
+		name := part.FormName()
+
+		if part.FileName() == "" {
+			value, err := p.readField(part)
+
+			if err != nil {
+				return upload, err
+			}
+
+			upload.Values[name] = append(upload.Values[name], value)
+
+			continue
+		}
+
+		if p.files++; p.files > p.cfg.MaxFiles {
+			return upload, &Error{Status: 413, Message: "Too many files (maximum " + strconv.Itoa(p.cfg.MaxFiles) + ")"}
+		}
+
+		file := &UploadedFile{Field: name, Filename: part.FileName(), Header: part.Header}
+		err = p.writeFile(part, file)
+
+		if file.Path != "" || err == nil {
+			upload.Files[name] = append(upload.Files[name], file)
+		}
+
+		if err != nil {
+			return upload, err
+		}
+	}
+}
+
+// readField read a part which is not a file
+func (p *uploadParser) readField(part *multipart.Part) (string, error) {
+	limit := p.cfg.MaxFieldSize
+
+	if p.remaining < limit {
+		limit = p.remaining
+	}
+
+	value, err := ioutil.ReadAll(io.LimitReader(part, limit+1))
+
+	if err != nil {
+		return "", &Error{Status: 400, Message: "Invalid multipart body : " + err.Error()}
+	}
+
+	if int64(len(value)) > limit {
+		return "", &Error{Status: 413, Message: "Field too large : " + part.FormName()}
+	}
+
+	p.remaining -= int64(len(value))
+
+	return string(value), nil
+}
+
+// writeFile detect the content type of the file part and copy it in its
+// destination
+func (p *uploadParser) writeFile(part *multipart.Part, file *UploadedFile) error {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return &Error{Status: 400, Message: "Invalid multipart body : " + err.Error()}
+	}
+
+	head = head[:n]
+	file.ContentType = http.DetectContentType(head)
+
+	if len(p.cfg.AllowedTypes) > 0 && matchContentType(file.ContentType, p.cfg.AllowedTypes) == false {
+		return &Error{Status: 415, Message: "File type not allowed : " + mediaTypeOf(file.ContentType)}
+	}
+
+	dst, err := p.destination(file)
+
+	if err != nil {
+		return err
+	}
+
+	// the temporary files are closed, the writers of the configuration are
+	// left to their owner
+	if tmp, ok := dst.(*os.File); ok == true && file.Path != "" {
+		defer tmp.Close()
+	}
+
+	limit := p.cfg.MaxFileSize
+
+	if p.remaining < limit {
+		limit = p.remaining
+	}
+
+	written, err := io.Copy(dst, io.LimitReader(io.MultiReader(bytes.NewReader(head), part), limit+1))
+	file.Size = written
+
+	if err != nil {
+		return err
+	}
+
+	if written > limit {
+		return &Error{Status: 413, Message: "File too large : " + file.Filename + " (maximum " + strconv.FormatInt(limit, 10) + " bytes)"}
+	}
+
+	p.remaining -= written
+
+	return nil
+}
+
+// destination return the writer of the file: the one of the configuration or
+// a temporary file
+func (p *uploadParser) destination(file *UploadedFile) (io.Writer, error) {
+	if p.cfg.Destination != nil {
+		dst, err := p.cfg.Destination(file)
+
+		if err != nil || dst != nil {
+			return dst, err
+		}
+	}
+
+	tmp, err := ioutil.TempFile(p.cfg.Dir, "thttp-upload-")
+
+	if err != nil {
+		return nil, err
+	}
+
+	file.Path = tmp.Name()
+
+	return tmp, nil
+}
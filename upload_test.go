@@ -0,0 +1,210 @@
+package thttp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nanux-io/nanux"
+)
+
+var pngContent = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+type testPart struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+func newUploadRequest(parts []testPart) nanux.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	for _, part := range parts {
+		if part.filename == "" {
+			w.WriteField(part.field, string(part.content))
+			continue
+		}
+
+		pw, _ := w.CreateFormFile(part.field, part.filename)
+		pw.Write(part.content)
+	}
+
+	w.Close()
+
+	return NewRequest(RequestConfig{
+		Method: "POST",
+		Header: http.Header{"Content-Type": {w.FormDataContentType()}},
+		Body:   body.Bytes(),
+	})
+}
+
+func TestParseUpload(t *testing.T) {
+	parts := []testPart{
+		{field: "title", content: []byte("report")},
+		{field: "doc", filename: "report.txt", content: []byte("hello world")},
+		{field: "doc", filename: "logo.png", content: pngContent},
+	}
+
+	emptyFiles := make([]testPart, DefaultMaxUploadFiles+1)
+
+	for i := range emptyFiles {
+		emptyFiles[i] = testPart{field: "doc", filename: "empty.txt"}
+	}
+
+	tests := []struct {
+		name       string
+		req        nanux.Request
+		cfg        UploadConfig
+		wantStatus int
+	}{
+		{name: "files and fields", req: newUploadRequest(parts)},
+		{name: "allowed types", req: newUploadRequest(parts), cfg: UploadConfig{AllowedTypes: []string{"text/*", "image/png"}}},
+		{name: "type not allowed", req: newUploadRequest(parts), cfg: UploadConfig{AllowedTypes: []string{"image/*"}}, wantStatus: 415},
+		{name: "file too large", req: newUploadRequest(parts), cfg: UploadConfig{MaxFileSize: 100}, wantStatus: 413},
+		{name: "upload too large", req: newUploadRequest(parts), cfg: UploadConfig{MaxSize: 50}, wantStatus: 413},
+		{name: "field too large", req: newUploadRequest(parts), cfg: UploadConfig{MaxFieldSize: 3}, wantStatus: 413},
+		{name: "too many files", req: newUploadRequest(parts), cfg: UploadConfig{MaxFiles: 1}, wantStatus: 413},
+		{name: "too many parts", req: newUploadRequest(parts), cfg: UploadConfig{MaxParts: 2}, wantStatus: 413},
+		{
+			name:       "many empty files",
+			req:        newUploadRequest(emptyFiles),
+			wantStatus: 413,
+		},
+		{
+			name:       "not multipart",
+			req:        NewRequest(RequestConfig{Method: "POST", Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte("{}")}),
+			wantStatus: 415,
+		},
+		{
+			name:       "malformed body",
+			req:        NewRequest(RequestConfig{Method: "POST", Header: http.Header{"Content-Type": {"multipart/form-data; boundary=xyz"}}, Body: []byte("--xyz\r\nbroken")}),
+			wantStatus: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := ioutil.TempDir("", "thttp-upload-test")
+			defer os.RemoveAll(dir)
+
+			tt.cfg.Dir = dir
+			upload, err := ParseUpload(tt.req, tt.cfg)
+
+			if tt.wantStatus != 0 {
+				if err == nil || errorStatusCode(err) != tt.wantStatus {
+					t.Errorf("ParseUpload() err = %v, want status %d", err, tt.wantStatus)
+				}
+
+				if entries, _ := ioutil.ReadDir(dir); len(entries) != 0 {
+					t.Errorf("ParseUpload() must remove the temporary files on error, found %d", len(entries))
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseUpload() err = %v", err)
+			}
+
+			if title := upload.Values["title"]; len(title) != 1 || title[0] != "report" {
+				t.Errorf("ParseUpload() values = %v", upload.Values)
+			}
+
+			files := upload.Files["doc"]
+
+			if len(files) != 2 {
+				t.Fatalf("ParseUpload() files = %v", files)
+			}
+
+			wantTypes := []string{"text/plain; charset=utf-8", "image/png"}
+
+			for i, file := range files {
+				content, _ := ioutil.ReadFile(file.Path)
+
+				if bytes.Equal(content, parts[i+1].content) == false || file.Size != int64(len(content)) {
+					t.Errorf("ParseUpload() file %s content = %q, size = %d", file.Filename, content, file.Size)
+				}
+
+				if file.ContentType != wantTypes[i] {
+					t.Errorf("ParseUpload() file %s content type = %s, want %s", file.Filename, file.ContentType, wantTypes[i])
+				}
+			}
+
+			if err := upload.RemoveAll(); err != nil {
+				t.Errorf("Upload.RemoveAll() err = %v", err)
+			}
+
+			if entries, _ := ioutil.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("Upload.RemoveAll() left %d files", len(entries))
+			}
+		})
+	}
+}
+
+func TestParseUpload_destination(t *testing.T) {
+	var buf bytes.Buffer
+
+	cfg := UploadConfig{
+		Destination: func(file *UploadedFile) (io.Writer, error) {
+			if strings.HasPrefix(file.ContentType, "image/") == true {
+				return &buf, nil
+			}
+
+			return nil, nil
+		},
+	}
+
+	upload, err := ParseUpload(newUploadRequest([]testPart{
+		{field: "logo", filename: "logo.png", content: pngContent},
+		{field: "doc", filename: "report.txt", content: []byte("hello world")},
+	}), cfg)
+
+	if err != nil {
+		t.Fatalf("ParseUpload() err = %v", err)
+	}
+
+	defer upload.RemoveAll()
+
+	if bytes.Equal(buf.Bytes(), pngContent) == false || upload.File("logo").Path != "" {
+		t.Errorf("ParseUpload() must write the file in the destination writer")
+	}
+
+	if upload.File("doc").Path == "" {
+		t.Errorf("ParseUpload() must write the file in a temporary file when the destination is nil")
+	}
+}
+
+func TestUploads(t *testing.T) {
+	var path string
+
+	handler := Uploads(UploadConfig{})(func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+		file := GetUpload(req).File("doc")
+		path = file.Path
+
+		f, err := file.Open()
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		return ioutil.ReadAll(f)
+	})
+
+	resp, err := handler(nil, newUploadRequest([]testPart{{field: "doc", filename: "report.txt", content: []byte("hello world")}}))
+
+	if err != nil || string(resp) != "hello world" {
+		t.Fatalf("Uploads() resp = %s, err = %v", resp, err)
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) == false {
+		t.Errorf("Uploads() must remove the temporary files once the handler returns")
+	}
+}
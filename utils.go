@@ -1,9 +1,12 @@
 package thttp
 
 import (
+	"context"
 	"errors"
+	"strings"
 
 	"github.com/nanux-io/nanux"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
 )
@@ -13,7 +16,7 @@ func GetHTTPCtx(req nanux.Request) (httpCtx *fasthttp.RequestCtx, err error) {
 	httpCtxI, ok := req.M["httpCtx"]
 
 	if ok == false {
-		log.Error().Msg("GetHTTPCtx : could not extract http context from request")
+		GetLogger(req).Error().Msg("GetHTTPCtx : could not extract http context from request")
 
 		return nil, errors.New("Internal server error")
 	}
@@ -21,10 +24,64 @@ func GetHTTPCtx(req nanux.Request) (httpCtx *fasthttp.RequestCtx, err error) {
 	httpCtx, ok = httpCtxI.(*fasthttp.RequestCtx)
 
 	if ok == false {
-		log.Error().Msg("GetHTTPCtx : could not convert http context to *fasthttp.RequestCtx")
+		GetLogger(req).Error().Msg("GetHTTPCtx : could not convert http context to *fasthttp.RequestCtx")
 
 		return nil, errors.New("Internal server error")
 	}
 
 	return
 }
+
+// GetLogger return the request scoped logger injected by the transporter in the
+// nanux request. If there is none, the global logger of zerolog is returned.
+func GetLogger(req nanux.Request) *zerolog.Logger {
+	if logger, ok := req.M["logger"].(*zerolog.Logger); ok == true {
+		return logger
+	}
+
+	return &log.Logger
+}
+
+// matchContentType tell if the media type of the content type (without its
+// parameters) matches one of the patterns. A pattern like "text/*" matches all
+// the subtypes of text and "*/*" matches everything.
+func matchContentType(contentType string, patterns []string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+
+		if strings.HasSuffix(pattern, "/*") == true && strings.HasPrefix(mediaType, pattern[:len(pattern)-1]) == true {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetContext return the context of the request injected by the transporter in
+// the nanux request. It is cancelled when the transporter is closed and when
+// the timeout of the route or the one requested by the client is reached. If
+// there is none, context.Background() is returned.
+func GetContext(req nanux.Request) context.Context {
+	if ctx, ok := req.M["context"].(context.Context); ok == true {
+		return ctx
+	}
+
+	return context.Background()
+}
+
+// GetHandlerOpts return the options of the handler associated to the route of
+// the request. Middlewares use it to read the route specific options. If there
+// is none, an empty nanux.HandlerOpts is returned.
+func GetHandlerOpts(req nanux.Request) nanux.HandlerOpts {
+	if opts, ok := req.M["handlerOpts"].(nanux.HandlerOpts); ok == true {
+		return opts
+	}
+
+	return nanux.HandlerOpts{}
+}
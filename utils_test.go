@@ -1,10 +1,14 @@
 package thttp
 
 import (
+	"context"
+	"io/ioutil"
 	"reflect"
 	"testing"
 
 	"github.com/nanux-io/nanux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
 )
 
@@ -49,3 +53,152 @@ func TestGetHTTPCtx(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLogger(t *testing.T) {
+	reqLogger := zerolog.New(ioutil.Discard).With().Str("path", "/test").Logger()
+
+	tests := []struct {
+		name       string
+		req        nanux.Request
+		wantLogger *zerolog.Logger
+	}{
+		{
+			name:       "logger not provided",
+			req:        nanux.Request{M: make(map[string]interface{})},
+			wantLogger: &log.Logger,
+		},
+		{
+			name:       "logger is not of type *zerolog.Logger",
+			req:        nanux.Request{M: map[string]interface{}{"logger": "wrong type"}},
+			wantLogger: &log.Logger,
+		},
+		{
+			name:       "logger type is *zerolog.Logger",
+			req:        nanux.Request{M: map[string]interface{}{"logger": &reqLogger}},
+			wantLogger: &reqLogger,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotLogger := GetLogger(tt.req); gotLogger != tt.wantLogger {
+				t.Errorf("GetLogger() = %v, want %v", gotLogger, tt.wantLogger)
+			}
+		})
+	}
+}
+
+func TestTransporter_getLogger(t *testing.T) {
+	logger := zerolog.New(ioutil.Discard).With().Str("service", "orders").Logger()
+
+	// the global logger is read when it is used, so that it can be configured
+	// after the transporter is created
+	tr := New("127.0.0.1:1234", false)
+
+	if got := tr.getLogger(); got != &log.Logger {
+		t.Errorf("Transporter.getLogger() = %p, want the global logger %p", got, &log.Logger)
+	}
+
+	tr = New("127.0.0.1:1234", false, WithLogger(logger))
+
+	if got := tr.getLogger(); reflect.DeepEqual(*got, logger) == false {
+		t.Errorf("Transporter.getLogger() = %v, want the logger of WithLogger", got)
+	}
+}
+
+func TestMatchContentType(t *testing.T) {
+	patterns := []string{"text/*", "application/json"}
+
+	tests := []struct {
+		name        string
+		contentType string
+		patterns    []string
+		want        bool
+	}{
+		{name: "exact match", contentType: "application/json", patterns: patterns, want: true},
+		{name: "match with parameters", contentType: "application/json; charset=utf-8", patterns: patterns, want: true},
+		{name: "match is case insensitive", contentType: "Application/JSON", patterns: patterns, want: true},
+		{name: "match subtype wildcard", contentType: "text/html", patterns: patterns, want: true},
+		{name: "no match", contentType: "image/png", patterns: patterns, want: false},
+		{name: "no match on prefix", contentType: "textual/html", patterns: patterns, want: false},
+		{name: "match everything", contentType: "image/png", patterns: []string{"*/*"}, want: true},
+		{name: "no pattern", contentType: "text/html", patterns: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchContentType(tt.contentType, tt.patterns); got != tt.want {
+				t.Errorf("matchContentType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetContext(t *testing.T) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		req     nanux.Request
+		wantCtx context.Context
+	}{
+		{
+			name:    "context not provided",
+			req:     nanux.Request{M: make(map[string]interface{})},
+			wantCtx: context.Background(),
+		},
+		{
+			name:    "context is not of type context.Context",
+			req:     nanux.Request{M: map[string]interface{}{"context": "wrong type"}},
+			wantCtx: context.Background(),
+		},
+		{
+			name:    "context type is context.Context",
+			req:     nanux.Request{M: map[string]interface{}{"context": reqCtx}},
+			wantCtx: reqCtx,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotCtx := GetContext(tt.req); gotCtx != tt.wantCtx {
+				t.Errorf("GetContext() = %v, want %v", gotCtx, tt.wantCtx)
+			}
+		})
+	}
+}
+
+func TestGetHandlerOpts(t *testing.T) {
+	opts := nanux.HandlerOpts{MethodsOpt: Methods{Get: true}}
+
+	tests := []struct {
+		name     string
+		req      nanux.Request
+		wantOpts nanux.HandlerOpts
+	}{
+		{
+			name:     "handler options not provided",
+			req:      nanux.Request{M: make(map[string]interface{})},
+			wantOpts: nanux.HandlerOpts{},
+		},
+		{
+			name:     "handler options are not of type nanux.HandlerOpts",
+			req:      nanux.Request{M: map[string]interface{}{"handlerOpts": "wrong type"}},
+			wantOpts: nanux.HandlerOpts{},
+		},
+		{
+			name:     "handler options type is nanux.HandlerOpts",
+			req:      nanux.Request{M: map[string]interface{}{"handlerOpts": opts}},
+			wantOpts: opts,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotOpts := GetHandlerOpts(tt.req); !reflect.DeepEqual(gotOpts, tt.wantOpts) {
+				t.Errorf("GetHandlerOpts() = %v, want %v", gotOpts, tt.wantOpts)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package thttp
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// regexps caches the compiled regexps of the validation rules
+var regexps sync.Map
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Validate check the fields of the struct according to their `validate` tag.
+// The rules are separated by commas:
+//
+//   - required: the field must not be the zero value
+//   - omitempty: the other rules are not checked if the field is the zero value
+//   - min=n, max=n: minimum and maximum length of a string, a slice or a map,
+//     or minimum and maximum value of a number
+//   - regex=expr: the string must match the expression, which can not contain
+//     commas
+//   - enum=a|b|c: the value must be one of the listed values
+//
+// Nested structs are validated too. A *ValidationError listing the invalid
+// fields is returned.
+func Validate(v interface{}) error {
+	var fields []FieldError
+
+	validateStruct(reflect.ValueOf(v), "", &fields)
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+// validateStruct validate the fields of the struct, prefixing their name
+func validateStruct(v reflect.Value, prefix string, fields *[]FieldError) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() == true {
+			return
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		// unexported field
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		fv := v.Field(i)
+		name := prefix + fieldName(sf)
+
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			if fe, ok := validateField(fv, name, tag); ok == false {
+				*fields = append(*fields, fe)
+				continue
+			}
+		}
+
+		inner := fv
+
+		for inner.Kind() == reflect.Ptr && inner.IsNil() == false {
+			inner = inner.Elem()
+		}
+
+		if inner.Kind() == reflect.Struct && inner.Type() != timeType {
+			validateStruct(inner, name+".", fields)
+		}
+	}
+}
+
+// validateField check the rules of the tag on the value. The first rule which
+// is not respected is returned.
+func validateField(v reflect.Value, name, tag string) (FieldError, bool) {
+	rules := strings.Split(tag, ",")
+
+	for _, rule := range rules {
+		if rule == "required" && v.IsZero() == true {
+			return FieldError{Field: name, Rule: "required", Message: "is required"}, false
+		}
+
+		if rule == "omitempty" && v.IsZero() == true {
+			return FieldError{}, true
+		}
+	}
+
+	for v.Kind() == reflect.Ptr {
+		// the value of an optional field is not checked when it is not set
+		if v.IsNil() == true {
+			return FieldError{}, true
+		}
+
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		ruleName, param := rule, ""
+
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			ruleName, param = rule[:i], rule[i+1:]
+		}
+
+		var message string
+
+		switch ruleName {
+		case "required", "omitempty", "":
+			continue
+		case "min", "max":
+			message = checkBound(v, ruleName, param)
+		case "regex":
+			message = checkRegex(v, param)
+		case "enum":
+			message = checkEnum(v, param)
+		default:
+			message = "has an unknown validation rule " + ruleName
+		}
+
+		if message != "" {
+			return FieldError{Field: name, Rule: ruleName, Message: message}, false
+		}
+	}
+
+	return FieldError{}, true
+}
+
+// checkBound check the min or max rule. An empty message is returned if the
+// value respects it.
+func checkBound(v reflect.Value, rule, param string) string {
+	bound, err := strconv.ParseFloat(param, 64)
+
+	if err != nil {
+		return "has an invalid " + rule + " rule"
+	}
+
+	var value float64
+	unit := ""
+
+	switch v.Kind() {
+	case reflect.String:
+		value = float64(utf8.RuneCountInString(v.String()))
+		unit = " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		value = float64(v.Len())
+		unit = " items long"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		value = v.Float()
+	default:
+		return "can not be checked with the " + rule + " rule"
+	}
+
+	if rule == "min" && value < bound {
+		return "must be at least " + param + unit
+	}
+
+	if rule == "max" && value > bound {
+		return "must be at most " + param + unit
+	}
+
+	return ""
+}
+
+// checkRegex check that the string matches the expression
+func checkRegex(v reflect.Value, expr string) string {
+	if v.Kind() != reflect.String {
+		return "can not be checked with the regex rule"
+	}
+
+	var re *regexp.Regexp
+
+	if cached, ok := regexps.Load(expr); ok == true {
+		re = cached.(*regexp.Regexp)
+	} else {
+		var err error
+
+		if re, err = regexp.Compile(expr); err != nil {
+			return "has an invalid regex rule"
+		}
+
+		regexps.Store(expr, re)
+	}
+
+	if re.MatchString(v.String()) == false {
+		return "must match " + expr
+	}
+
+	return ""
+}
+
+// checkEnum check that the value is one of the values separated by "|"
+func checkEnum(v reflect.Value, param string) string {
+	values := strings.Split(param, "|")
+	value := fmt.Sprint(v.Interface())
+
+	for _, allowed := range values {
+		if value == allowed {
+			return ""
+		}
+	}
+
+	return "must be one of " + strings.Join(values, ", ")
+}
+
+// fieldName return the name of the field in the requests: the name of its
+// json tag, then of its form tag, or the name of the field
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		if name := strings.Split(sf.Tag.Get(key), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return sf.Name
+}
@@ -0,0 +1,175 @@
+package thttp
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"omitempty,regex=^[0-9]{5}$"`
+}
+
+type testOrder struct {
+	Name     string       `json:"name" validate:"required,min=3,max=10"`
+	Quantity int          `json:"quantity" validate:"min=1,max=100"`
+	Price    *float64     `json:"price" validate:"min=0.5"`
+	Status   string       `json:"status" validate:"enum=draft|paid"`
+	Tags     []string     `form:"tag" validate:"max=2"`
+	Address  testAddress  `json:"address"`
+	Billing  *testAddress `json:"billing"`
+	internal string       `validate:"required"`
+}
+
+func TestValidate(t *testing.T) {
+	price := 0.1
+
+	valid := func() testOrder {
+		return testOrder{Name: "order", Quantity: 1, Status: "draft", Address: testAddress{City: "Paris"}}
+	}
+
+	tests := []struct {
+		name       string
+		order      func() testOrder
+		wantFields []FieldError
+	}{
+		{
+			name:  "valid",
+			order: valid,
+		},
+		{
+			name: "required",
+			order: func() testOrder {
+				o := valid()
+				o.Name = ""
+
+				return o
+			},
+			wantFields: []FieldError{{Field: "name", Rule: "required", Message: "is required"}},
+		},
+		{
+			name: "string length",
+			order: func() testOrder {
+				o := valid()
+				o.Name = "an order too long"
+
+				return o
+			},
+			wantFields: []FieldError{{Field: "name", Rule: "max", Message: "must be at most 10 characters long"}},
+		},
+		{
+			name: "number bounds",
+			order: func() testOrder {
+				o := valid()
+				o.Quantity = 0
+
+				return o
+			},
+			wantFields: []FieldError{{Field: "quantity", Rule: "min", Message: "must be at least 1"}},
+		},
+		{
+			name: "pointer checked when set",
+			order: func() testOrder {
+				o := valid()
+				o.Price = &price
+
+				return o
+			},
+			wantFields: []FieldError{{Field: "price", Rule: "min", Message: "must be at least 0.5"}},
+		},
+		{
+			name: "enum",
+			order: func() testOrder {
+				o := valid()
+				o.Status = "cancelled"
+
+				return o
+			},
+			wantFields: []FieldError{{Field: "status", Rule: "enum", Message: "must be one of draft, paid"}},
+		},
+		{
+			name: "slice length with form name",
+			order: func() testOrder {
+				o := valid()
+				o.Tags = []string{"a", "b", "c"}
+
+				return o
+			},
+			wantFields: []FieldError{{Field: "tag", Rule: "max", Message: "must be at most 2 items long"}},
+		},
+		{
+			name: "nested structs",
+			order: func() testOrder {
+				o := valid()
+				o.Address = testAddress{Zip: "750"}
+				o.Billing = &testAddress{City: "Lyon", Zip: "ABCDE"}
+
+				return o
+			},
+			wantFields: []FieldError{
+				{Field: "address.city", Rule: "required", Message: "is required"},
+				{Field: "address.zip", Rule: "regex", Message: "must match ^[0-9]{5}$"},
+				{Field: "billing.zip", Rule: "regex", Message: "must match ^[0-9]{5}$"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.order()
+			err := Validate(&order)
+
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("Validate() err = %v", err)
+				}
+
+				return
+			}
+
+			validationErr, ok := err.(*ValidationError)
+
+			if ok == false {
+				t.Fatalf("Validate() err = %v, want a *ValidationError", err)
+			}
+
+			if reflect.DeepEqual(validationErr.Fields, tt.wantFields) == false {
+				t.Errorf("Validate() fields = %+v, want %+v", validationErr.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidate_invalidRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		wantRule string
+	}{
+		{name: "unknown rule", value: &struct {
+			A string `validate:"email"`
+		}{}, wantRule: "email"},
+		{name: "invalid bound", value: &struct {
+			A string `validate:"min=a"`
+		}{}, wantRule: "min"},
+		{name: "invalid regex", value: &struct {
+			A string `validate:"regex=["`
+		}{}, wantRule: "regex"},
+		{name: "regex on a number", value: &struct {
+			A int `validate:"regex=^1$"`
+		}{}, wantRule: "regex"},
+		{name: "bound on a bool", value: &struct {
+			A bool `validate:"max=1"`
+		}{}, wantRule: "max"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validationErr, ok := Validate(tt.value).(*ValidationError)
+
+			if ok == false || validationErr.Fields[0].Rule != tt.wantRule {
+				t.Errorf("Validate() err = %v, want an error on rule %s", validationErr, tt.wantRule)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package thttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultWebhookTolerance is the default maximum age of a timestamped webhook
+const DefaultWebhookTolerance = 5 * time.Minute
+
+var (
+	errWebhookSignature = errors.New("Webhook : missing or invalid signature")
+	errWebhookTimestamp = errors.New("Webhook : missing or invalid timestamp")
+	errWebhookExpired   = errors.New("Webhook : timestamp outside of the tolerance")
+)
+
+// WebhookVerifier checks the signature of a webhook request. body is the raw
+// body of the request and now the time of the verification.
+type WebhookVerifier func(httpCtx *fasthttp.RequestCtx, body []byte, now time.Time) error
+
+// VerifyWebhook return a middleware checking the signature of the webhooks
+// with the verifier before calling the handler. Requests with an invalid
+// signature or sent outside the tolerance of the verifier are answered with a
+// 403 status code. It panics if the verifier is nil.
+func VerifyWebhook(verifier WebhookVerifier) nanux.Middleware {
+	if verifier == nil {
+		panic("VerifyWebhook : verifier must be set")
+	}
+
+	return func(fn nanux.HandlerFunc) nanux.HandlerFunc {
+		return func(ctx *interface{}, req nanux.Request) ([]byte, error) {
+			httpCtx, err := GetHTTPCtx(req)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if err := verifier(httpCtx, req.Data, time.Now()); err != nil {
+				GetLogger(req).Warn().Err(err).Msg("VerifyWebhook : request rejected")
+				httpCtx.SetStatusCode(fasthttp.StatusForbidden)
+
+				return nil, nil
+			}
+
+			return fn(ctx, req)
+		}
+	}
+}
+
+// GitHubSignature return a verifier of the GitHub webhooks, signed with the
+// secret in the X-Hub-Signature-256 header
+func GitHubSignature(secret string) WebhookVerifier {
+	return func(httpCtx *fasthttp.RequestCtx, body []byte, _ time.Time) error {
+		signature := string(httpCtx.Request.Header.Peek("X-Hub-Signature-256"))
+
+		if strings.HasPrefix(signature, "sha256=") == false {
+			return errWebhookSignature
+		}
+
+		return checkHMAC(sha256.New, []byte(secret), body, signature[len("sha256="):], hex.DecodeString)
+	}
+}
+
+// StripeSignature return a verifier of the Stripe webhooks, signed with the
+// secret in the Stripe-Signature header. Webhooks older than the tolerance
+// are rejected, the tolerance default to DefaultWebhookTolerance.
+func StripeSignature(secret string, tolerance time.Duration) WebhookVerifier {
+	if tolerance == 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+
+	return func(httpCtx *fasthttp.RequestCtx, body []byte, now time.Time) error {
+		var timestamp string
+		var signatures []string
+
+		// the header contains the timestamp and one signature per secret of
+		// the endpoint: t=1492774577,v1=5257a869...,v1=...
+		for _, part := range strings.Split(string(httpCtx.Request.Header.Peek("Stripe-Signature")), ",") {
+			switch {
+			case strings.HasPrefix(part, "t="):
+				timestamp = part[2:]
+			case strings.HasPrefix(part, "v1="):
+				signatures = append(signatures, part[3:])
+			}
+		}
+
+		if err := checkTimestamp(timestamp, now, tolerance); err != nil {
+			return err
+		}
+
+		payload := append([]byte(timestamp+"."), body...)
+
+		for _, signature := range signatures {
+			if checkHMAC(sha256.New, []byte(secret), payload, signature, hex.DecodeString) == nil {
+				return nil
+			}
+		}
+
+		return errWebhookSignature
+	}
+}
+
+// HMACConfig define the configuration of a generic HMAC verifier. The signed
+// message is the timestamp and the values of the signed headers, each followed
+// by a new line, and then the body.
+type HMACConfig struct {
+	// Secret shared with the sender
+	Secret []byte
+	// Hash used by the HMAC. Default to sha256.New.
+	Hash func() hash.Hash
+	// Header containing the signature. Default to "X-Signature".
+	Header string
+	// Prefix of the signature in the header (eg: "sha256=")
+	Prefix string
+	// Base64 tells if the signature is encoded in base64 instead of hex
+	Base64 bool
+	// SignedHeaders are the headers whose values are signed with the body
+	SignedHeaders []string
+	// TimestampHeader contains the unix time at which the webhook was sent. It
+	// is signed and the webhooks older than the tolerance are rejected. The
+	// timestamp is not checked if it is empty.
+	TimestampHeader string
+	// Tolerance is the maximum age of the webhooks. Default to
+	// DefaultWebhookTolerance.
+	Tolerance time.Duration
+}
+
+// HMACSignature return a verifier of webhooks signed with an HMAC
+func HMACSignature(cfg HMACConfig) WebhookVerifier {
+	if cfg.Hash == nil {
+		cfg.Hash = sha256.New
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = "X-Signature"
+	}
+
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = DefaultWebhookTolerance
+	}
+
+	decode := hex.DecodeString
+
+	if cfg.Base64 == true {
+		decode = base64.StdEncoding.DecodeString
+	}
+
+	return func(httpCtx *fasthttp.RequestCtx, body []byte, now time.Time) error {
+		var message []byte
+
+		if cfg.TimestampHeader != "" {
+			timestamp := string(httpCtx.Request.Header.Peek(cfg.TimestampHeader))
+
+			if err := checkTimestamp(timestamp, now, cfg.Tolerance); err != nil {
+				return err
+			}
+
+			message = append(message, timestamp+"\n"...)
+		}
+
+		for _, header := range cfg.SignedHeaders {
+			message = append(message, httpCtx.Request.Header.Peek(header)...)
+			message = append(message, '\n')
+		}
+
+		message = append(message, body...)
+		signature := string(httpCtx.Request.Header.Peek(cfg.Header))
+
+		if strings.HasPrefix(signature, cfg.Prefix) == false {
+			return errWebhookSignature
+		}
+
+		return checkHMAC(cfg.Hash, cfg.Secret, message, signature[len(cfg.Prefix):], decode)
+	}
+}
+
+// checkHMAC compare in constant time the encoded signature to the HMAC of the
+// message
+func checkHMAC(h func() hash.Hash, secret, message []byte, signature string, decode func(string) ([]byte, error)) error {
+	expected, err := decode(signature)
+
+	if err != nil {
+		return errWebhookSignature
+	}
+
+	mac := hmac.New(h, secret)
+	mac.Write(message)
+
+	if hmac.Equal(mac.Sum(nil), expected) == false {
+		return errWebhookSignature
+	}
+
+	return nil
+}
+
+// checkTimestamp tells if the unix timestamp is within the tolerance, in the
+// past or in the future to allow for clock skew
+func checkTimestamp(timestamp string, now time.Time, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil {
+		return errWebhookTimestamp
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+
+	if age > tolerance || age < -tolerance {
+		return errWebhookExpired
+	}
+
+	return nil
+}
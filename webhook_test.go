@@ -0,0 +1,224 @@
+package thttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nanux-io/nanux"
+	"github.com/valyala/fasthttp"
+)
+
+// sign return the HMAC of the message with the secret
+func sign(h func() hash.Hash, secret, message string) []byte {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(message))
+
+	return mac.Sum(nil)
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := `{"action":"opened"}`
+	verifier := GitHubSignature("secret")
+
+	tests := []struct {
+		name           string
+		signature      string
+		wantStatusCode int
+		wantCalled     bool
+	}{
+		{name: "valid signature", signature: "sha256=" + hex.EncodeToString(sign(sha256.New, "secret", body)), wantStatusCode: 200, wantCalled: true},
+		{name: "invalid signature", signature: "sha256=" + hex.EncodeToString(sign(sha256.New, "other", body)), wantStatusCode: 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("X-Hub-Signature-256", tt.signature)
+			req := nanux.Request{Data: []byte(body), M: map[string]interface{}{"httpCtx": httpCtx}}
+
+			called := false
+
+			_, err := VerifyWebhook(verifier)(func(_ *interface{}, req nanux.Request) ([]byte, error) {
+				called = true
+
+				return nil, nil
+			})(nil, req)
+
+			if err != nil {
+				t.Fatalf("VerifyWebhook() - error occured when calling handler - %s", err)
+			}
+
+			if statusCode := httpCtx.Response.StatusCode(); statusCode != tt.wantStatusCode {
+				t.Errorf("VerifyWebhook() - status code = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+
+			if called != tt.wantCalled {
+				t.Errorf("VerifyWebhook() - handler called = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhook_missingVerifier(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("VerifyWebhook() must panic when the verifier is not set")
+		}
+	}()
+
+	VerifyWebhook(nil)
+}
+
+func TestGitHubSignature(t *testing.T) {
+	body := `{"action":"opened"}`
+	valid := hex.EncodeToString(sign(sha256.New, "secret", body))
+
+	tests := []struct {
+		name      string
+		signature string
+		wantErr   error
+	}{
+		{name: "valid signature", signature: "sha256=" + valid},
+		{name: "other secret", signature: "sha256=" + hex.EncodeToString(sign(sha256.New, "other", body)), wantErr: errWebhookSignature},
+		{name: "missing prefix", signature: valid, wantErr: errWebhookSignature},
+		{name: "invalid hex", signature: "sha256=zz", wantErr: errWebhookSignature},
+		{name: "missing header", wantErr: errWebhookSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("X-Hub-Signature-256", tt.signature)
+
+			if err := GitHubSignature("secret")(httpCtx, []byte(body), time.Now()); err != tt.wantErr {
+				t.Errorf("GitHubSignature() err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStripeSignature(t *testing.T) {
+	body := `{"type":"charge.succeeded"}`
+	now := time.Unix(1600000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	valid := hex.EncodeToString(sign(sha256.New, "whsec", timestamp+"."+body))
+	other := hex.EncodeToString(sign(sha256.New, "other", timestamp+"."+body))
+
+	tests := []struct {
+		name      string
+		header    string
+		now       time.Time
+		tolerance time.Duration
+		wantErr   error
+	}{
+		{name: "valid signature", header: "t=" + timestamp + ",v1=" + valid, now: now},
+		{name: "one of the signatures valid", header: "t=" + timestamp + ",v1=" + other + ",v1=" + valid + ",v0=ignored", now: now},
+		{name: "invalid signature", header: "t=" + timestamp + ",v1=" + other, now: now, wantErr: errWebhookSignature},
+		{name: "no signature", header: "t=" + timestamp, now: now, wantErr: errWebhookSignature},
+		{name: "within the default tolerance", header: "t=" + timestamp + ",v1=" + valid, now: now.Add(4 * time.Minute)},
+		{name: "replayed outside the tolerance", header: "t=" + timestamp + ",v1=" + valid, now: now.Add(6 * time.Minute), wantErr: errWebhookExpired},
+		{name: "custom tolerance", header: "t=" + timestamp + ",v1=" + valid, now: now.Add(2 * time.Minute), tolerance: time.Minute, wantErr: errWebhookExpired},
+		{name: "timestamp in the future", header: "t=" + timestamp + ",v1=" + valid, now: now.Add(-6 * time.Minute), wantErr: errWebhookExpired},
+		{name: "missing timestamp", header: "v1=" + valid, now: now, wantErr: errWebhookTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+			httpCtx.Request.Header.Set("Stripe-Signature", tt.header)
+
+			if err := StripeSignature("whsec", tt.tolerance)(httpCtx, []byte(body), tt.now); err != tt.wantErr {
+				t.Errorf("StripeSignature() err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHMACSignature(t *testing.T) {
+	body := `{"event":"order.created"}`
+	now := time.Unix(1600000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	tests := []struct {
+		name    string
+		cfg     HMACConfig
+		headers map[string]string
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name:    "body only",
+			cfg:     HMACConfig{Secret: []byte("secret")},
+			headers: map[string]string{"X-Signature": hex.EncodeToString(sign(sha256.New, "secret", body))},
+		},
+		{
+			name: "custom header, prefix, hash and encoding",
+			cfg:  HMACConfig{Secret: []byte("secret"), Hash: sha1.New, Header: "X-Vendor-Signature", Prefix: "sha1=", Base64: true},
+			headers: map[string]string{
+				"X-Vendor-Signature": "sha1=" + base64.StdEncoding.EncodeToString(sign(sha1.New, "secret", body)),
+			},
+		},
+		{
+			name: "signed headers and timestamp",
+			cfg:  HMACConfig{Secret: []byte("secret"), SignedHeaders: []string{"X-Event-ID"}, TimestampHeader: "X-Timestamp"},
+			headers: map[string]string{
+				"X-Event-ID":  "42",
+				"X-Timestamp": timestamp,
+				"X-Signature": hex.EncodeToString(sign(sha256.New, "secret", timestamp+"\n42\n"+body)),
+			},
+			now: now,
+		},
+		{
+			name: "tampered signed header",
+			cfg:  HMACConfig{Secret: []byte("secret"), SignedHeaders: []string{"X-Event-ID"}},
+			headers: map[string]string{
+				"X-Event-ID":  "43",
+				"X-Signature": hex.EncodeToString(sign(sha256.New, "secret", "42\n"+body)),
+			},
+			wantErr: errWebhookSignature,
+		},
+		{
+			name: "replayed outside the tolerance",
+			cfg:  HMACConfig{Secret: []byte("secret"), TimestampHeader: "X-Timestamp", Tolerance: time.Minute},
+			headers: map[string]string{
+				"X-Timestamp": timestamp,
+				"X-Signature": hex.EncodeToString(sign(sha256.New, "secret", timestamp+"\n"+body)),
+			},
+			now:     now.Add(2 * time.Minute),
+			wantErr: errWebhookExpired,
+		},
+		{
+			name:    "missing prefix",
+			cfg:     HMACConfig{Secret: []byte("secret"), Prefix: "sha256="},
+			headers: map[string]string{"X-Signature": hex.EncodeToString(sign(sha256.New, "secret", body))},
+			wantErr: errWebhookSignature,
+		},
+		{
+			name:    "missing timestamp",
+			cfg:     HMACConfig{Secret: []byte("secret"), TimestampHeader: "X-Timestamp"},
+			headers: map[string]string{"X-Signature": hex.EncodeToString(sign(sha256.New, "secret", body))},
+			wantErr: errWebhookTimestamp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpCtx := &fasthttp.RequestCtx{}
+
+			for name, value := range tt.headers {
+				httpCtx.Request.Header.Set(name, value)
+			}
+
+			if err := HMACSignature(tt.cfg)(httpCtx, []byte(body), tt.now); err != tt.wantErr {
+				t.Errorf("HMACSignature() err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}